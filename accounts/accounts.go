@@ -0,0 +1,126 @@
+// Package accounts implements registration, login and key change of
+// SecureLogin users on top of securelogin.Verify.
+//
+// Sign-up and sign-in tokens look the same: both have empty scope and carry
+// the public key they are signed with. The difference is in what the
+// provider knows. A token registers an email which is not known yet, and
+// logs in to a known one only if it's signed by the key stored for it.
+package accounts
+
+import (
+	"context"
+	"time"
+
+	"github.com/vladimiroff/securelogin"
+)
+
+// Accounts registers and logs in users, keeping their credentials in a
+// Store.
+type Accounts struct {
+	// Store keeps the accounts.
+	Store Store
+
+	// Options to verify tokens with, e.g. securelogin.WithOrigins. The
+	// public key and the mode are set by each method.
+	Options []securelogin.Option
+
+	// EmailRules canonicalize emails before they are looked up, see
+	// securelogin.CanonicalEmail.
+	EmailRules []securelogin.EmailRule
+
+	now func() time.Time
+}
+
+// New returns Accounts, which verifies tokens with opts.
+func New(store Store, opts ...securelogin.Option) *Accounts {
+	return &Accounts{Store: store, Options: opts, now: time.Now}
+}
+
+// Register creates an account for the email of a sign-up token, signed by
+// the key it carries. It fails with ErrExists if the email is registered
+// already.
+func (a *Accounts) Register(ctx context.Context, token []byte) (Account, error) {
+	t, err := securelogin.VerifyContext(ctx, token, a.Options...)
+	if err != nil {
+		return Account{}, err
+	}
+
+	email, err := securelogin.CanonicalEmail(t.Email, a.EmailRules...)
+	if err != nil {
+		return Account{}, err
+	}
+
+	now := a.timeNow()
+	acc := Account{
+		Email:     email,
+		PublicKey: t.PublicKey,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := a.Store.Create(ctx, acc); err != nil {
+		return Account{}, err
+	}
+	return acc, nil
+}
+
+// Login returns the account of a sign-in token, which must be signed by the
+// key stored for it. It fails with ErrNotFound for unknown emails.
+func (a *Accounts) Login(ctx context.Context, token []byte) (Account, error) {
+	acc, _, err := a.verify(ctx, token)
+	return acc, err
+}
+
+// Change moves an account to the key in a change mode token signed by the
+// key stored for it, and returns the updated account. It fails with
+// ErrKeyChanged if the key was changed by someone else meanwhile.
+func (a *Accounts) Change(ctx context.Context, token []byte) (Account, error) {
+	acc, res, err := a.verify(ctx, token, securelogin.WithChange)
+	if err != nil {
+		return Account{}, err
+	}
+
+	to, err := res.Value.(securelogin.ChangeResult).PublicKey()
+	if err != nil {
+		return Account{}, err
+	}
+
+	now := a.timeNow()
+	if err := a.Store.UpdateKey(ctx, acc.Email, acc.PublicKey, to, now); err != nil {
+		return Account{}, err
+	}
+
+	acc.PublicKey, acc.UpdatedAt = to, now
+	return acc, nil
+}
+
+func (a *Accounts) timeNow() time.Time {
+	if a.now == nil {
+		return time.Now()
+	}
+	return a.now()
+}
+
+// verify checks that token is signed by the key stored for its email.
+func (a *Accounts) verify(ctx context.Context, token []byte, opts ...securelogin.Option) (Account, securelogin.Result, error) {
+	t, err := securelogin.Unmarshal(token)
+	if err != nil {
+		return Account{}, securelogin.Result{}, err
+	}
+
+	email, err := securelogin.CanonicalEmail(t.Email, a.EmailRules...)
+	if err != nil {
+		return Account{}, securelogin.Result{}, err
+	}
+
+	acc, err := a.Store.Get(ctx, email)
+	if err != nil {
+		return Account{}, securelogin.Result{}, err
+	}
+
+	opts = append(append(opts, a.Options...), securelogin.WithPublicKey(acc.PublicKey))
+	res, err := t.VerifyResultContext(ctx, opts...)
+	if err != nil {
+		return Account{}, res, err
+	}
+	return acc, res, nil
+}
@@ -0,0 +1,186 @@
+package accounts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/vladimiroff/securelogin"
+	"github.com/vladimiroff/securelogin/securelogintest"
+)
+
+var ctx = context.Background()
+
+func newAccounts() *Accounts {
+	return New(NewMemoryStore(), securelogin.WithOrigins(securelogintest.Origin))
+}
+
+func TestRegister(t *testing.T) {
+	a := newAccounts()
+	homer := securelogintest.New("homer@example.com")
+
+	acc, err := a.Register(ctx, homer.Encode())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if acc.Email != "homer@example.com" || !bytes.Equal(acc.PublicKey, homer.Key.PublicKey) {
+		t.Fatalf("Unexpected account %+v", acc)
+	}
+	if acc.CreatedAt.IsZero() {
+		t.Fatalf("Expected creation time to be set")
+	}
+
+	stored, err := a.Store.Get(ctx, "homer@example.com")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if stored.Fingerprint() != securelogin.NewFingerprint(homer.Key.PublicKey) {
+		t.Fatalf("Expected stored key to be the token's")
+	}
+
+	// Registering twice fails, regardless of the key.
+	homer.Key = securelogintest.NewKey("other")
+	if _, err := a.Register(ctx, homer.Encode()); !errors.Is(err, ErrExists) {
+		t.Fatalf("Expected ErrExists; got %v", err)
+	}
+}
+
+func TestRegisterRejectsInvalidTokens(t *testing.T) {
+	var cases = []securelogintest.Params{
+		securelogintest.New("homer@example.com").Expired(),
+		securelogintest.New("homer@example.com").BadSignature(),
+		securelogintest.New("homer@example.com").WrongClient(),
+		securelogintest.New("homer@example.com").Change(securelogintest.NewKey("new")),
+		securelogintest.New("not an email"),
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			a := newAccounts()
+			if _, err := a.Register(ctx, c.Encode()); err == nil {
+				t.Fatalf("Expected error; got nil")
+			}
+			if _, err := a.Store.Get(ctx, "homer@example.com"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Expected no account; got %v", err)
+			}
+		})
+	}
+
+	if _, err := newAccounts().Register(ctx, []byte("garbage")); err == nil {
+		t.Fatalf("Expected error; got nil")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	a := newAccounts()
+	a.EmailRules = []securelogin.EmailRule{securelogin.LowercaseLocal}
+
+	homer := securelogintest.New("Homer@Example.com")
+	if _, err := a.Register(ctx, homer.Encode()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	lower := homer
+	lower.Email = "homer@example.com"
+	acc, err := a.Login(ctx, lower.Encode())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if acc.Email != "homer@example.com" {
+		t.Fatalf("Expected canonical email; got %q", acc.Email)
+	}
+
+	var sigErr *securelogin.SignatureError
+	impostor := homer
+	impostor.Key = securelogintest.NewKey("impostor")
+	if _, err := a.Login(ctx, impostor.Encode()); !errors.As(err, &sigErr) {
+		t.Fatalf("Expected *SignatureError; got %v", err)
+	}
+
+	if _, err := a.Login(ctx, homer.Expired().Encode()); !errors.Is(err, securelogin.ErrExpired) {
+		t.Fatalf("Expected ErrExpired; got %v", err)
+	}
+
+	if _, err := a.Login(ctx, securelogintest.New("bart@example.com").Encode()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound; got %v", err)
+	}
+
+	if _, err := a.Login(ctx, []byte("garbage")); err == nil {
+		t.Fatalf("Expected error; got nil")
+	}
+}
+
+func TestChange(t *testing.T) {
+	a := newAccounts()
+	homer := securelogintest.New("homer@example.com")
+	if _, err := a.Register(ctx, homer.Encode()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// A sign-in token doesn't change the key.
+	if _, err := a.Change(ctx, homer.Encode()); err == nil {
+		t.Fatalf("Expected error; got nil")
+	}
+
+	newKey := securelogintest.NewKey("new")
+	acc, err := a.Change(ctx, homer.Change(newKey).Encode())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !bytes.Equal(acc.PublicKey, newKey.PublicKey) {
+		t.Fatalf("Expected new key in account")
+	}
+
+	// The old key can neither log in nor change the key again.
+	if _, err := a.Login(ctx, homer.Encode()); err == nil {
+		t.Fatalf("Expected error; got nil")
+	}
+	if _, err := a.Change(ctx, homer.Change(securelogintest.NewKey("evil")).Encode()); err == nil {
+		t.Fatalf("Expected error; got nil")
+	}
+
+	homer.Key = newKey
+	if _, err := a.Login(ctx, homer.Encode()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := a.Change(ctx, securelogintest.New("bart@example.com").Change(newKey).Encode()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound; got %v", err)
+	}
+}
+
+// racingStore changes the key right after it's read, as a concurrent Change
+// would.
+type racingStore struct {
+	*MemoryStore
+}
+
+func (s racingStore) Get(ctx context.Context, email string) (Account, error) {
+	a, err := s.MemoryStore.Get(ctx, email)
+	if err == nil {
+		s.MemoryStore.UpdateKey(ctx, email, a.PublicKey, []byte("raced"), a.UpdatedAt)
+	}
+	return a, err
+}
+
+func TestChangeConflict(t *testing.T) {
+	store := NewMemoryStore()
+	homer := securelogintest.New("homer@example.com")
+	if _, err := New(store, securelogin.WithOrigins(securelogintest.Origin)).Register(ctx, homer.Encode()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	a := New(racingStore{store}, securelogin.WithOrigins(securelogintest.Origin))
+	if _, err := a.Change(ctx, homer.Change(securelogintest.NewKey("new")).Encode()); !errors.Is(err, ErrKeyChanged) {
+		t.Fatalf("Expected ErrKeyChanged; got %v", err)
+	}
+}
+
+func TestZeroAccounts(t *testing.T) {
+	a := &Accounts{Store: NewMemoryStore(), Options: []securelogin.Option{securelogin.WithOrigins(securelogintest.Origin)}}
+	if _, err := a.Register(ctx, securelogintest.New("homer@example.com").Encode()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,113 @@
+package accounts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/vladimiroff/securelogin"
+)
+
+var (
+	// ErrNotFound is returned for unknown accounts.
+	ErrNotFound = errors.New("accounts: account not found")
+
+	// ErrExists is returned when registering an email twice.
+	ErrExists = errors.New("accounts: account already exists")
+
+	// ErrKeyChanged is returned when changing the key of an account whose
+	// key has been changed meanwhile.
+	ErrKeyChanged = errors.New("accounts: key has been changed")
+)
+
+// Account is a user registered with SecureLogin.
+type Account struct {
+	// Email of the user in canonical form, see securelogin.CanonicalEmail.
+	Email string
+
+	// PublicKey the user signs their tokens with.
+	PublicKey []byte
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Fingerprint returns the fingerprint of the account's public key.
+func (a Account) Fingerprint() securelogin.Fingerprint {
+	return securelogin.NewFingerprint(a.PublicKey)
+}
+
+// Store persists accounts. Implementations must be safe for concurrent use.
+type Store interface {
+	// Create stores a new account. It fails with ErrExists if an account
+	// with the same email exists.
+	Create(ctx context.Context, a Account) error
+
+	// Get returns the account of email, or ErrNotFound.
+	Get(ctx context.Context, email string) (Account, error)
+
+	// UpdateKey replaces the public key of the account of email with to,
+	// provided it's still from. Otherwise it fails with ErrKeyChanged, so
+	// only one of concurrent changes succeeds.
+	UpdateKey(ctx context.Context, email string, from, to []byte, at time.Time) error
+}
+
+// MemoryStore is a Store which keeps accounts in memory.
+type MemoryStore struct {
+	mu       sync.Mutex
+	accounts map[string]Account
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{accounts: make(map[string]Account)}
+}
+
+// Create implements Store.
+func (m *MemoryStore) Create(ctx context.Context, a Account) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.accounts[a.Email]; ok {
+		return ErrExists
+	}
+
+	a.PublicKey = append([]byte(nil), a.PublicKey...)
+	m.accounts[a.Email] = a
+	return nil
+}
+
+// Get implements Store.
+func (m *MemoryStore) Get(ctx context.Context, email string) (Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, ok := m.accounts[email]
+	if !ok {
+		return a, ErrNotFound
+	}
+
+	a.PublicKey = append([]byte(nil), a.PublicKey...)
+	return a, nil
+}
+
+// UpdateKey implements Store.
+func (m *MemoryStore) UpdateKey(ctx context.Context, email string, from, to []byte, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, ok := m.accounts[email]
+	if !ok {
+		return ErrNotFound
+	}
+	if !bytes.Equal(a.PublicKey, from) {
+		return ErrKeyChanged
+	}
+
+	a.PublicKey = append([]byte(nil), to...)
+	a.UpdatedAt = at
+	m.accounts[email] = a
+	return nil
+}
@@ -0,0 +1,53 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	var (
+		ctx = context.Background()
+		m   = NewMemoryStore()
+		key = []byte("key")
+		now = time.Now()
+	)
+
+	if _, err := m.Get(ctx, "homer@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound; got %v", err)
+	}
+
+	if err := m.Create(ctx, Account{Email: "homer@example.com", PublicKey: key}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := m.Create(ctx, Account{Email: "homer@example.com"}); !errors.Is(err, ErrExists) {
+		t.Fatalf("Expected ErrExists; got %v", err)
+	}
+
+	// Stored keys don't alias the caller's.
+	key[0] = 'X'
+	a, err := m.Get(ctx, "homer@example.com")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if string(a.PublicKey) != "key" {
+		t.Fatalf("Expected key %q; got %q", "key", a.PublicKey)
+	}
+
+	if err := m.UpdateKey(ctx, "homer@example.com", []byte("other"), []byte("new"), now); !errors.Is(err, ErrKeyChanged) {
+		t.Fatalf("Expected ErrKeyChanged; got %v", err)
+	}
+	if err := m.UpdateKey(ctx, "bart@example.com", nil, []byte("new"), now); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound; got %v", err)
+	}
+	if err := m.UpdateKey(ctx, "homer@example.com", []byte("key"), []byte("new"), now); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	a, _ = m.Get(ctx, "homer@example.com")
+	if string(a.PublicKey) != "new" || !a.UpdatedAt.Equal(now) {
+		t.Fatalf("Expected updated key; got %q at %s", a.PublicKey, a.UpdatedAt)
+	}
+}
@@ -0,0 +1,79 @@
+package securelogin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// base45Alphabet is the QR code alphanumeric mode charset, as of RFC 9285.
+const base45Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// base45Prefix is how the binary encoding starts in base45. The text form of
+// a token never contains a comma, unlike the standard one.
+var base45Prefix = EncodeBase45([]byte{binaryMagic, binaryFormat})
+
+// EncodeBase45 returns the base45 encoding of data as of RFC 9285. Its
+// charset is the one of QR code alphanumeric mode, which stores it more
+// densely than base64 could be, so
+//
+//	EncodeBase45(MarshalBinaryWithSecret(t))
+//
+// is the text form of a token to be shown as a QR code. Encoder writes it
+// with SetBase45 and Decoder recognizes it.
+func EncodeBase45(data []byte) string {
+	var b strings.Builder
+	b.Grow(len(data)/2*3 + 2)
+
+	for len(data) >= 2 {
+		n := int(data[0])<<8 | int(data[1])
+		b.WriteByte(base45Alphabet[n%45])
+		b.WriteByte(base45Alphabet[n/45%45])
+		b.WriteByte(base45Alphabet[n/45/45])
+		data = data[2:]
+	}
+	if len(data) == 1 {
+		n := int(data[0])
+		b.WriteByte(base45Alphabet[n%45])
+		b.WriteByte(base45Alphabet[n/45])
+	}
+
+	return b.String()
+}
+
+func isBase45(s []byte) bool {
+	return bytes.HasPrefix(s, []byte(base45Prefix)) && !bytes.Contains(s, comma)
+}
+
+// DecodeBase45 returns the bytes represented by base45 encoded s.
+func DecodeBase45(s string) ([]byte, error) {
+	if len(s)%3 == 1 {
+		return nil, errors.New("base45: invalid length")
+	}
+
+	data := make([]byte, 0, len(s)/3*2+1)
+	for i := 0; i < len(s); i += 3 {
+		chunk := s[i:min(i+3, len(s))]
+
+		n := 0
+		for j := len(chunk) - 1; j >= 0; j-- {
+			d := strings.IndexByte(base45Alphabet, chunk[j])
+			if d < 0 {
+				return nil, fmt.Errorf("base45: illegal character %q at %d", chunk[j], i+j)
+			}
+			n = n*45 + d
+		}
+
+		switch {
+		case len(chunk) == 3 && n <= 0xFFFF:
+			data = append(data, byte(n>>8), byte(n))
+		case len(chunk) == 2 && n <= 0xFF:
+			data = append(data, byte(n))
+		default:
+			return nil, fmt.Errorf("base45: invalid chunk %q", chunk)
+		}
+	}
+
+	return data, nil
+}
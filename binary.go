@@ -0,0 +1,224 @@
+package securelogin
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// The binary encoding starts with binaryMagic, which no encoded sltoken
+// starts with, and binaryFormat followed by flags.
+const (
+	binaryMagic  = 0x00
+	binaryFormat = 0x01
+)
+
+const (
+	// flagRawPayload marks the signed payload stored as is, instead of as
+	// its fields.
+	flagRawPayload = 1 << iota
+	// flagSameClient marks the client left out for being the provider.
+	flagSameClient
+	// flagRawExpire marks the expiration stored as text, as it isn't in
+	// the canonical form a varint would restore.
+	flagRawExpire
+)
+
+var errBinaryTruncated = errors.New("truncated")
+
+// MarshalBinary returns t in the compact binary encoding, which is meant for
+// QR codes and constrained transports. The HMAC secret is left out, see
+// MarshalBinaryWithSecret.
+//
+// The encoding is made of the version and the fields of the signed payload
+// followed by the raw signatures, keys and the email, each of them prefixed
+// by its length as a varint. The expiration time is a varint as well. It
+// preserves the signed payload byte for byte, so UnmarshalBinary restores a
+// token which encodes with Marshal as the original.
+func MarshalBinary(t Token) []byte {
+	return appendBinary(nil, t, false)
+}
+
+// MarshalBinaryWithSecret returns t in the compact binary encoding including
+// its HMAC secret, see MarshalBinary.
+func MarshalBinaryWithSecret(t Token) []byte {
+	return appendBinary(nil, t, true)
+}
+
+func appendBinary(b []byte, t Token, withSecret bool) []byte {
+	var (
+		flags  byte
+		fields = strings.Split(string(t.rawPayload), ",")
+		expire int64
+	)
+
+	switch {
+	case len(fields) != 4:
+		flags |= flagRawPayload
+	default:
+		if fields[0] == fields[1] {
+			flags |= flagSameClient
+		}
+
+		var err error
+		expire, err = strconv.ParseInt(fields[3], 10, 64)
+		if err != nil || strconv.FormatInt(expire, 10) != fields[3] {
+			flags |= flagRawExpire
+		}
+	}
+
+	b = append(b, binaryMagic, binaryFormat, flags)
+	b = binary.AppendUvarint(b, uint64(t.Version))
+
+	if flags&flagRawPayload != 0 {
+		b = appendBytes(b, t.rawPayload)
+	} else {
+		b = appendBytes(b, []byte(fields[0]))
+		if flags&flagSameClient == 0 {
+			b = appendBytes(b, []byte(fields[1]))
+		}
+		b = appendBytes(b, []byte(fields[2]))
+		if flags&flagRawExpire != 0 {
+			b = appendBytes(b, []byte(fields[3]))
+		} else {
+			b = binary.AppendVarint(b, expire)
+		}
+	}
+
+	var secret []byte
+	if withSecret {
+		secret = t.HMACSecret.Bytes()
+	}
+
+	b = appendBytes(b, t.Signature)
+	b = appendBytes(b, t.HMACSignature)
+	b = appendBytes(b, t.PublicKey)
+	b = appendBytes(b, secret)
+	return appendBytes(b, []byte(t.Email))
+}
+
+func appendBytes(b, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// UnmarshalBinary parses a token in the compact binary encoding, see
+// MarshalBinary. The token is validated as Unmarshal would validate its
+// standard encoding.
+func UnmarshalBinary(data []byte) (Token, error) {
+	s, err := binaryToString(data)
+	if err != nil {
+		return Token{}, wrap("binary", err)
+	}
+	return UnmarshalString(s)
+}
+
+// binaryToString converts the binary encoding to the standard one.
+func binaryToString(data []byte) (string, error) {
+	r := binaryReader{data: data}
+
+	header := r.next(3)
+	if r.err != nil {
+		return "", r.err
+	}
+	if header[0] != binaryMagic || header[1] != binaryFormat {
+		return "", errors.New("unknown format")
+	}
+	flags := header[2]
+
+	version := r.uvarint()
+
+	var payload string
+	if flags&flagRawPayload != 0 {
+		payload = string(r.bytes())
+	} else {
+		provider := string(r.bytes())
+		client := provider
+		if flags&flagSameClient == 0 {
+			client = string(r.bytes())
+		}
+		scope := string(r.bytes())
+
+		var expire string
+		if flags&flagRawExpire != 0 {
+			expire = string(r.bytes())
+		} else {
+			expire = strconv.FormatInt(r.varint(), 10)
+		}
+		payload = strings.Join([]string{provider, client, scope, expire}, ",")
+	}
+
+	var (
+		signature     = r.bytes()
+		hmacSignature = r.bytes()
+		publicKey     = r.bytes()
+		secret        = r.bytes()
+		email         = r.bytes()
+	)
+	if r.err != nil {
+		return "", r.err
+	}
+	if len(r.data) > 0 {
+		return "", errors.New("trailing data")
+	}
+	if version > 1<<16 {
+		return "", errors.New("invalid version")
+	}
+
+	return versionPrefix(int(version)) + escapeJoin([]string{
+		payload,
+		escapeJoin([]string{base64Encode(signature), base64Encode(hmacSignature)}),
+		escapeJoin([]string{base64Encode(publicKey), base64Encode(secret)}),
+		string(email),
+	}), nil
+}
+
+// binaryReader reads the binary encoding, remembering the first error.
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binaryReader) next(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.data)) {
+		r.err = errBinaryTruncated
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errBinaryTruncated
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = errBinaryTruncated
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) bytes() []byte {
+	return r.next(r.uvarint())
+}
@@ -0,0 +1,188 @@
+package securelogin
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	var connect Token
+	connect.SetPayload(Payload{
+		Provider: "https://cobased.com",
+		Client:   "https://app.cobased.com",
+		Scope:    url.Values{"mode": {"change"}, "to": {"a+b/c="}},
+		ExpireAt: time.Unix(-1, 0),
+	})
+	connect.Email = "a,b@cobased.com"
+	connect.HMACSecret = NewSecret([]byte("secret"))
+
+	var raw Token
+	raw.rawPayload = []byte("https://cobased.com,https://cobased.com,,+0123")
+
+	var cases = []string{
+		token,
+		v2Token(),
+		MarshalString(connect),
+		string(MarshalWithSecret(connect)),
+		MarshalString(raw),
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			tok, err := UnmarshalString(c)
+			if err != nil {
+				// Tokens not passing Unmarshal are converted as is.
+				tok = raw
+			}
+
+			data := MarshalBinaryWithSecret(tok)
+			got, err := UnmarshalBinary(data)
+			fatal(t, err)
+
+			if s := string(MarshalWithSecret(got)); s != c {
+				t.Fatalf("Expected:\t%s\nGot:\t\t\t%s", c, s)
+			}
+			if !bytes.Equal(got.SignedMessage(), tok.SignedMessage()) {
+				t.Fatalf("Expected signed message %q; got %q", tok.SignedMessage(), got.SignedMessage())
+			}
+
+			text := EncodeBase45(data)
+			decoded, err := DecodeBase45(text)
+			fatal(t, err)
+			if !bytes.Equal(decoded, data) {
+				t.Fatalf("Expected base45 to round trip")
+			}
+		})
+	}
+}
+
+func TestBinaryVerifies(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	data := MarshalBinaryWithSecret(tok)
+	if len(data) >= len(token)*3/4 {
+		t.Errorf("Expected binary encoding to be compact; got %d bytes for %d", len(data), len(token))
+	}
+
+	got, err := UnmarshalBinary(data)
+	fatal(t, err)
+	fatal(t, got.Verify(o, WithHMAC, WithoutExpire))
+
+	// The secret is only encoded on request.
+	got, err = UnmarshalBinary(MarshalBinary(tok))
+	fatal(t, err)
+	if got.HMACSecret.Len() != 0 {
+		t.Fatalf("Expected secret to be left out")
+	}
+	fatal(t, got.Verify(o, WithoutExpire))
+}
+
+func TestBinaryEncoderDecoder(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	enc.SetBinary(true)
+	enc.SetIncludeSecret(true)
+	fatal(t, enc.Encode(tok))
+
+	if !bytes.Equal(buf.Bytes(), MarshalBinaryWithSecret(tok)) {
+		t.Fatalf("Expected encoder to write the binary encoding")
+	}
+
+	var decoded Token
+	fatal(t, NewDecoder(buf).Decode(&decoded))
+	if got := string(MarshalWithSecret(decoded)); got != token {
+		t.Fatalf("Expected:\t%s\nGot:\t\t\t%s", token, got)
+	}
+}
+
+func TestBase45EncoderDecoder(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	enc.SetBase45(true)
+	enc.SetIncludeSecret(true)
+	fatal(t, enc.Encode(tok))
+
+	if want := EncodeBase45(MarshalBinaryWithSecret(tok)); buf.String() != want {
+		t.Fatalf("Expected:\t%s\nGot:\t\t\t%s", want, buf)
+	}
+
+	var decoded Token
+	fatal(t, NewDecoder(strings.NewReader(buf.String()+"\n")).Decode(&decoded))
+	if got := string(MarshalWithSecret(decoded)); got != token {
+		t.Fatalf("Expected:\t%s\nGot:\t\t\t%s", token, got)
+	}
+
+	if err := NewDecoder(strings.NewReader(base45Prefix + "!")).Decode(&decoded); err == nil {
+		t.Fatalf("Expected error; got nil")
+	}
+}
+
+func TestUnmarshalBinaryErrors(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+	data := MarshalBinaryWithSecret(tok)
+
+	var cases = []struct {
+		data []byte
+		err  string
+	}{
+		{nil, "truncated"},
+		{[]byte{binaryMagic, 0x7F, 0}, "unknown format"},
+		{data[:len(data)-1], "truncated"},
+		{append(data[:len(data):len(data)], 0), "trailing data"},
+		{[]byte{binaryMagic, binaryFormat, flagRawPayload, 0, 1, 'x', 0, 0, 0, 0, 0}, "in payload expected 4 elements"},
+		{[]byte{binaryMagic, binaryFormat, flagRawPayload, 9, 0, 0, 0, 0, 0, 0}, "unsupported version 9"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			_, err := UnmarshalBinary(c.data)
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("Expected error %q; got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestBase45(t *testing.T) {
+	// Examples of RFC 9285.
+	var cases = []struct {
+		data, text string
+	}{
+		{"AB", "BB8"},
+		{"Hello!!", "%69 VD92EX0"},
+		{"base-45", "UJCLQE7W581"},
+		{"ietf!", "QED8WEX0"},
+		{"", ""},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if got := EncodeBase45([]byte(c.data)); got != c.text {
+				fail(t, "base45", c.text, got)
+			}
+			got, err := DecodeBase45(c.text)
+			fatal(t, err)
+			if string(got) != c.data {
+				fail(t, "decoded", c.data, string(got))
+			}
+		})
+	}
+
+	for _, invalid := range []string{"A", "GGW", "ZZZZ", "aB8"} {
+		if _, err := DecodeBase45(invalid); err == nil {
+			t.Errorf("Expected error decoding %q; got nil", invalid)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Command slprovider is a reference SecureLogin provider.
+//
+// It implements sign-up, sign-in, change of the user's key, Connect and
+// logout, and keeps users in a JSON file. It's meant as a runnable example
+// rather than a production server.
+//
+// Sign-up and sign-in tokens must carry the nonce issued to the browser
+// with the index page, which guards against replay and login CSRF.
+//
+// Usage:
+//
+//	slprovider -origin https://example.com -addr :8080 -users users.json
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+)
+
+func main() {
+	var (
+		addr   = flag.String("addr", ":8080", "address to listen on")
+		origin = flag.String("origin", "http://localhost:8080", "origin of the provider, as seen by the browser")
+		users  = flag.String("users", "users.json", "path to the users file")
+	)
+	flag.Parse()
+
+	s, err := openStore(*users)
+	if err != nil {
+		log.Fatalf("slprovider: %s", err)
+	}
+
+	log.Printf("slprovider: serving %s on %s", *origin, *addr)
+	log.Fatal(http.ListenAndServe(*addr, newServer(*origin, s)))
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vladimiroff/securelogin"
+)
+
+const (
+	sessionCookie = "session"
+
+	// nonceTTL is how long a rendered sign-in page stays usable.
+	nonceTTL = 10 * time.Minute
+)
+
+// server is a SecureLogin provider for a single origin.
+type server struct {
+	origin string
+	users  *store
+	nonces securelogin.NonceStore
+	mux    *http.ServeMux
+
+	mu       sync.Mutex
+	sessions map[string]string // session id to email
+}
+
+func newServer(origin string, users *store) *server {
+	s := &server{
+		origin:   origin,
+		users:    users,
+		nonces:   securelogin.NewMemoryNonceStore(),
+		mux:      http.NewServeMux(),
+		sessions: make(map[string]string),
+	}
+
+	s.mux.HandleFunc("/", s.index)
+	s.mux.HandleFunc("/me", s.me)
+	s.mux.HandleFunc("/signup", post(s.signup))
+	s.mux.HandleFunc("/signin", post(s.signin))
+	s.mux.HandleFunc("/change", post(s.change))
+	s.mux.HandleFunc("/connect", post(s.connect))
+	s.mux.HandleFunc("/logout", post(s.logout))
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func post(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!doctype html>
+<title>SecureLogin provider</title>
+{{if .Email}}
+<p>Logged in as {{.Email}}.</p>
+<form method="post" action="/logout"><button>Log out</button></form>
+{{else}}
+<p><a href="{{.SignUp}}">Sign up</a> or <a href="{{.SignIn}}">sign in</a> with SecureLogin.</p>
+<p>No app? Use the <a href="{{.SignInWeb}}">web version</a>.</p>
+{{end}}
+`))
+
+func (s *server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if email, ok := s.session(r); ok {
+		indexTemplate.Execute(w, map[string]interface{}{"Email": email})
+		return
+	}
+
+	// Tokens are only accepted with a nonce issued for this browser, so
+	// they can't be replayed or used to log someone else in.
+	nonce, err := securelogin.NewNonce(r.Context(), s.nonces, s.browser(w, r), nonceTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	signUp, signIn := securelogin.NewRequest(s.origin), securelogin.NewRequest(s.origin)
+	signUp.Scope = url.Values{securelogin.NonceKey: {nonce}}
+	signIn.Scope = signUp.Scope
+	signUp.Callback = s.origin + "/signup"
+	signIn.Callback = s.origin + "/signin"
+
+	data := make(map[string]template.URL)
+	for _, link := range []struct {
+		name  string
+		req   securelogin.Request
+		build func(securelogin.Request) string
+	}{
+		{"SignUp", signUp, securelogin.Request.AppURL},
+		{"SignIn", signIn, securelogin.Request.AppURL},
+		{"SignInWeb", signIn, securelogin.Request.WebURL},
+	} {
+		if data[link.name], err = s.requestURL(link.req, link.build); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	indexTemplate.Execute(w, data)
+}
+
+// requestURL checks that req is valid for the origin and returns its URL
+// built by build. html/template only trusts http(s) and mailto links, so
+// the securelogin:// URL has to be marked as safe.
+func (s *server) requestURL(req securelogin.Request, build func(securelogin.Request) string) (template.URL, error) {
+	if err := req.Validate(securelogin.WithOrigins(s.origin)); err != nil {
+		return "", err
+	}
+
+	u := build(req)
+	if !strings.HasPrefix(u, securelogin.AppURL+"#") && !strings.HasPrefix(u, securelogin.WebURL+"#") {
+		return "", errors.New("unexpected request URL")
+	}
+	return template.URL(u), nil
+}
+
+func (s *server) me(w http.ResponseWriter, r *http.Request) {
+	email, ok := s.session(r)
+	if !ok {
+		http.Error(w, "not logged in", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, map[string]string{"email": email})
+}
+
+// signup registers the user of a sign-in token, whose email is not yet
+// known, and logs them in.
+func (s *server) signup(w http.ResponseWriter, r *http.Request) {
+	t, err := securelogin.VerifyContext(r.Context(), []byte(r.PostFormValue("sltoken")),
+		securelogin.WithOrigins(s.origin), s.withNonce(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	id, err := securelogin.NewIdentity(t)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.users.create(user{Email: id.Email, PublicKey: t.PublicKey}); err != nil {
+		httpError(w, err)
+		return
+	}
+
+	s.login(w, id.Email)
+	writeJSON(w, map[string]string{"email": id.Email})
+}
+
+// signin logs in a known user with a token signed by their key.
+func (s *server) signin(w http.ResponseWriter, r *http.Request) {
+	u, _, err := s.verify(r, securelogin.WithOrigins(s.origin), s.withNonce(r))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+
+	s.login(w, u.Email)
+	writeJSON(w, map[string]string{"email": u.Email})
+}
+
+// change moves a known user to the public key in a change mode token
+// signed by their current key.
+func (s *server) change(w http.ResponseWriter, r *http.Request) {
+	u, res, err := s.verify(r, securelogin.WithOrigins(s.origin), securelogin.WithChange)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+
+	u.PublicKey, err = res.Value.(securelogin.ChangeResult).PublicKey()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.users.update(u); err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, map[string]string{"email": u.Email})
+}
+
+// connect authorizes another client to act on behalf of a known user, in
+// place of an OAuth flow.
+func (s *server) connect(w http.ResponseWriter, r *http.Request) {
+	u, res, err := s.verify(r, securelogin.WithOrigins(s.origin), securelogin.WithConnect)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]string{"email": u.Email, "client": res.Token.Client})
+}
+
+func (s *server) logout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		s.mu.Lock()
+		delete(s.sessions, c.Value)
+		s.mu.Unlock()
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verify checks that the posted token is signed by the stored key of its
+// user.
+func (s *server) verify(r *http.Request, opts ...securelogin.Option) (user, securelogin.Result, error) {
+	var res securelogin.Result
+
+	t, err := securelogin.UnmarshalString(r.PostFormValue("sltoken"))
+	if err != nil {
+		return user{}, res, &verifyError{err}
+	}
+
+	email, err := securelogin.CanonicalEmail(t.Email)
+	if err != nil {
+		return user{}, res, &verifyError{err}
+	}
+
+	u, err := s.users.get(email)
+	if err != nil {
+		return u, res, err
+	}
+
+	res, err = t.VerifyResultContext(r.Context(), append(opts, securelogin.WithPublicKey(u.PublicKey))...)
+	if err != nil {
+		return u, res, &verifyError{err}
+	}
+	return u, res, nil
+}
+
+// withNonce requires the token to carry a nonce issued to the browser
+// making r.
+func (s *server) withNonce(r *http.Request) securelogin.Option {
+	var id string
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		id = c.Value
+	}
+	return securelogin.WithNonce(s.nonces, id)
+}
+
+type verifyError struct{ err error }
+
+func (e *verifyError) Error() string { return e.err.Error() }
+
+// login starts a session for email under a new id, so that an id known
+// before the login can't be used to take it over.
+func (s *server) login(w http.ResponseWriter, email string) {
+	id := newSessionID()
+
+	s.mu.Lock()
+	s.sessions[id] = email
+	s.mu.Unlock()
+
+	setSessionCookie(w, id)
+}
+
+// browser returns the session id of the browser making r, giving it a new
+// one if it has none yet. Ids of anonymous browsers are only used to bind
+// nonces to them.
+func (s *server) browser(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(sessionCookie); err == nil && c.Value != "" {
+		return c.Value
+	}
+
+	id := newSessionID()
+	setSessionCookie(w, id)
+	return id
+}
+
+func newSessionID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+func setSessionCookie(w http.ResponseWriter, id string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (s *server) session(r *http.Request) (string, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	email, ok := s.sessions[c.Value]
+	return email, ok
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	var verr *verifyError
+
+	switch {
+	case errors.Is(err, errUnknownUser):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errUserExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.As(err, &verr):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/vladimiroff/securelogin"
+	"github.com/vladimiroff/securelogin/securelogintest"
+)
+
+type client struct {
+	t  *testing.T
+	ts *httptest.Server
+	c  *http.Client
+}
+
+func newClient(t *testing.T, path string) *client {
+	s, err := openStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	ts := httptest.NewServer(newServer(securelogintest.Origin, s))
+	t.Cleanup(ts.Close)
+	return (&client{t: t, ts: ts}).browser()
+}
+
+// browser returns a client of the same server with its own cookies.
+func (c *client) browser() *client {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		c.t.Fatalf("Unexpected error: %s", err)
+	}
+	return &client{t: c.t, ts: c.ts, c: &http.Client{Jar: jar}}
+}
+
+var hrefRegexp = regexp.MustCompile(`href="([^"]*)"`)
+
+// links loads the index page and returns the requests it links to, in
+// order.
+func (c *client) links() []securelogin.Request {
+	c.t.Helper()
+
+	resp, err := c.c.Get(c.ts.URL + "/")
+	if err != nil {
+		c.t.Fatalf("Unexpected error: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var reqs []securelogin.Request
+	for _, m := range hrefRegexp.FindAllStringSubmatch(string(body), -1) {
+		req, err := securelogin.ParseRequest(html.UnescapeString(m[1]))
+		if err != nil {
+			c.t.Fatalf("Unexpected error: %s in %s", err, body)
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs
+}
+
+// withNonce returns a copy of p carrying a nonce issued to c.
+func (c *client) withNonce(p securelogintest.Params) securelogintest.Params {
+	c.t.Helper()
+
+	links := c.links()
+	if len(links) == 0 {
+		c.t.Fatalf("Expected links on the index page")
+	}
+	p.Scope = links[0].Scope
+	return p
+}
+
+func (c *client) post(path string, p securelogintest.Params, status int) map[string]string {
+	c.t.Helper()
+
+	resp, err := c.c.PostForm(c.ts.URL+path, url.Values{"sltoken": {string(p.Encode())}})
+	if err != nil {
+		c.t.Fatalf("Unexpected error: %s", err)
+	}
+	return c.check(resp, status)
+}
+
+func (c *client) get(path string, status int) map[string]string {
+	c.t.Helper()
+
+	resp, err := c.c.Get(c.ts.URL + path)
+	if err != nil {
+		c.t.Fatalf("Unexpected error: %s", err)
+	}
+	return c.check(resp, status)
+}
+
+func (c *client) check(resp *http.Response, status int) map[string]string {
+	c.t.Helper()
+	defer resp.Body.Close()
+
+	if resp.StatusCode != status {
+		c.t.Fatalf("%s %s: expected status %d; got %d", resp.Request.Method, resp.Request.URL.Path, status, resp.StatusCode)
+	}
+
+	var body map[string]string
+	if resp.Header.Get("Content-Type") == "application/json" {
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			c.t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	return body
+}
+
+func TestLifecycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	c := newClient(t, path)
+
+	const email = "Homer@Example.com"
+	alice := securelogintest.New(email)
+
+	c.get("/me", http.StatusUnauthorized)
+	if got := c.post("/signup", c.withNonce(alice), http.StatusOK)["email"]; got != "Homer@example.com" {
+		t.Errorf("Expected canonical email; got %q", got)
+	}
+	c.get("/me", http.StatusOK)
+
+	c.post("/logout", alice, http.StatusNoContent)
+	c.get("/me", http.StatusUnauthorized)
+
+	c.post("/signin", c.withNonce(alice), http.StatusOK)
+	c.get("/me", http.StatusOK)
+	c.post("/logout", alice, http.StatusNoContent)
+	c.post("/signup", c.withNonce(alice), http.StatusConflict)
+
+	newKey := securelogintest.NewKey("new")
+	c.post("/change", alice.Change(newKey), http.StatusOK)
+
+	c.post("/signin", c.withNonce(alice), http.StatusForbidden)
+	rotated := alice
+	rotated.Key = newKey
+	c.post("/signin", c.withNonce(rotated), http.StatusOK)
+
+	connect := rotated
+	connect.Client = "https://app.example.net"
+	if got := c.post("/connect", connect, http.StatusOK)["client"]; got != connect.Client {
+		t.Errorf("Expected client %q; got %q", connect.Client, got)
+	}
+	c.post("/connect", alice, http.StatusForbidden)
+
+	// The new key survives a restart.
+	c = newClient(t, path)
+	c.post("/signin", c.withNonce(alice), http.StatusForbidden)
+	c.post("/signin", securelogintest.New("bart@example.com"), http.StatusNotFound)
+	c.post("/signin", c.withNonce(rotated), http.StatusOK)
+}
+
+func TestIndexLinks(t *testing.T) {
+	c := newClient(t, filepath.Join(t.TempDir(), "users.json"))
+
+	resp, err := c.c.Get(c.ts.URL + "/")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	for _, want := range []string{
+		`href="` + securelogin.AppURL + `#`,
+		`href="` + securelogin.WebURL + `#`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected %s in %s", want, body)
+		}
+	}
+
+	links := c.links()
+	if len(links) != 3 {
+		t.Fatalf("Expected 3 links; got %d", len(links))
+	}
+	for i, callback := range []string{"/signup", "/signin", "/signin"} {
+		req := links[i]
+		if req.Provider != securelogintest.Origin || req.Callback != securelogintest.Origin+callback {
+			t.Errorf("%d: unexpected request %#v", i, req)
+		}
+		if req.Scope.Get(securelogin.NonceKey) == "" {
+			t.Errorf("%d: expected a nonce; got scope %v", i, req.Scope)
+		}
+	}
+}
+
+func TestNonce(t *testing.T) {
+	c := newClient(t, filepath.Join(t.TempDir(), "users.json"))
+	homer := securelogintest.New("homer@example.com")
+	c.post("/signup", c.withNonce(homer), http.StatusOK)
+	c.post("/logout", homer, http.StatusNoContent)
+
+	// Missing nonce.
+	c.post("/signin", homer, http.StatusForbidden)
+
+	// A nonce is good for a single token.
+	signin := c.withNonce(homer)
+	c.post("/signin", signin, http.StatusOK)
+	c.post("/logout", homer, http.StatusNoContent)
+	c.post("/signin", signin, http.StatusForbidden)
+
+	// A token for one browser doesn't log in another.
+	other := c.browser()
+	other.links()
+	other.post("/signin", c.withNonce(homer), http.StatusForbidden)
+	other.get("/me", http.StatusUnauthorized)
+}
+
+func TestRejectsInvalidTokens(t *testing.T) {
+	c := newClient(t, filepath.Join(t.TempDir(), "users.json"))
+
+	c.post("/signup", securelogintest.New("lisa@example.com").Expired(), http.StatusForbidden)
+	c.post("/signup", securelogintest.New("lisa@example.com").BadSignature(), http.StatusForbidden)
+	c.post("/signup", securelogintest.New("lisa@example.com").WrongClient(), http.StatusForbidden)
+	c.get("/signup", http.StatusMethodNotAllowed)
+	c.get("/", http.StatusOK)
+	c.get("/missing", http.StatusNotFound)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	errUserExists  = errors.New("user already exists")
+	errUnknownUser = errors.New("unknown user")
+)
+
+// user is an account, identified by its canonical email.
+type user struct {
+	Email     string `json:"email"`
+	PublicKey []byte `json:"public_key"`
+}
+
+// store keeps users in a JSON file, which is rewritten on every change.
+type store struct {
+	path string
+
+	mu    sync.Mutex
+	users map[string]user
+}
+
+// openStore loads users from path. Missing file means no users.
+func openStore(path string) (*store, error) {
+	s := &store{path: path, users: make(map[string]user)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return s, json.Unmarshal(data, &s.users)
+}
+
+func (s *store) get(email string) (user, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[email]
+	if !ok {
+		return u, errUnknownUser
+	}
+	return u, nil
+}
+
+func (s *store) create(u user) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[u.Email]; ok {
+		return errUserExists
+	}
+
+	s.users[u.Email] = u
+	if err := s.save(); err != nil {
+		delete(s.users, u.Email)
+		return err
+	}
+	return nil
+}
+
+func (s *store) update(u user) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.users[u.Email]
+	if !ok {
+		return errUnknownUser
+	}
+
+	s.users[u.Email] = u
+	if err := s.save(); err != nil {
+		s.users[u.Email] = old
+		return err
+	}
+	return nil
+}
+
+// save writes users to a temporary file first, so that a crash never
+// leaves a truncated store behind.
+func (s *store) save() error {
+	data, err := json.MarshalIndent(s.users, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
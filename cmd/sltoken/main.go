@@ -0,0 +1,143 @@
+// Command sltoken inspects SecureLogin tokens.
+//
+// Usage:
+//
+//	sltoken explain [flags] [token]
+//
+// Explain runs every verification check on the token, read from standard
+// input unless given as an argument, and prints a report of each along with
+// hints on why it failed. Options are read from the settings file, then from
+// SECURELOGIN_ environment variables and then from flags. It exits with
+// status 1 if the token fails any check.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/vladimiroff/securelogin"
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "explain" {
+		fmt.Fprintln(os.Stderr, "usage: sltoken explain [flags] [token]")
+		os.Exit(2)
+	}
+
+	ok, err := explain(os.Args[2:], os.Stdin, os.Stdout)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "sltoken: %s\n", err)
+		os.Exit(2)
+	case !ok:
+		os.Exit(1)
+	}
+}
+
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ",") }
+func (l *listFlag) Set(s string) error { *l = append(*l, s); return nil }
+
+// explain writes the report of the token in args or stdin to stdout and
+// reports whether the token passes every check.
+func explain(args []string, stdin io.Reader, stdout io.Writer) (bool, error) {
+	var (
+		s       securelogin.Settings
+		origins listFlag
+		modes   listFlag
+		fs      = flag.NewFlagSet("explain", flag.ContinueOnError)
+
+		settings     = fs.String("settings", "", "path to a JSON settings file")
+		publicKey    = fs.String("public-key", "", "base64 encoded public key to verify against")
+		secret       = fs.String("secret", "", "base64 encoded HMAC secret to verify against")
+		hmac         = fs.Bool("hmac", false, "verify the HMAC signature")
+		connect      = fs.Bool("connect", false, "accept any client, as in a Connect request")
+		change       = fs.Bool("change", false, "expect a change mode token")
+		scope        = fs.String("scope", "", "expected scope, URL encoded")
+		ignoreExpire = fs.Bool("ignore-expire", false, "don't check expiration")
+	)
+	fs.Var(&origins, "origin", "allowed origin, may be repeated")
+	fs.Var(&modes, "mode", "accepted mode, may be repeated")
+	fs.SetOutput(stdout)
+
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+
+	if *settings != "" {
+		f, err := os.Open(*settings)
+		if err != nil {
+			return false, err
+		}
+		s, err = securelogin.DecodeSettings(f)
+		f.Close()
+		if err != nil {
+			return false, err
+		}
+	}
+	if err := s.ApplyEnv(securelogin.DefaultEnvPrefix); err != nil {
+		return false, err
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "origin":
+			s.Origins = append(s.Origins, origins...)
+		case "mode":
+			s.Modes = append(s.Modes, modes...)
+		case "public-key":
+			s.PublicKey = *publicKey
+		case "secret":
+			s.HMACSecret = *secret
+		case "hmac":
+			s.HMAC = *hmac
+		case "connect":
+			s.Connect = *connect
+		case "change":
+			s.Change = *change
+		case "ignore-expire":
+			s.IgnoreExpire = *ignoreExpire
+		case "scope":
+			if s.Scope, err = url.ParseQuery(*scope); err != nil {
+				err = fmt.Errorf("invalid -scope: %s", err)
+			}
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+
+	opts, err := s.Options()
+	if err != nil {
+		return false, err
+	}
+
+	if fs.NArg() > 1 {
+		return false, fmt.Errorf("expected a single token; got %d arguments", fs.NArg())
+	}
+
+	token := fs.Arg(0)
+	if token == "" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return false, err
+		}
+		token = string(data)
+	}
+
+	report := securelogin.Explain([]byte(strings.TrimSpace(token)), opts...)
+	fmt.Fprint(stdout, report)
+	if err := report.Err(); err != nil {
+		fmt.Fprintf(stdout, "\nverify would fail with: %s\n", err)
+	}
+	return report.OK(), nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/vladimiroff/securelogin/securelogintest"
+)
+
+func TestExplain(t *testing.T) {
+	var (
+		valid   = string(securelogintest.New("homer@example.com").Encode())
+		expired = string(securelogintest.New("homer@example.com").Expired().Encode())
+	)
+
+	var cases = []struct {
+		args  []string
+		stdin string
+		ok    bool
+		out   string
+	}{
+		{[]string{"-origin", securelogintest.Origin, valid}, "", true, "signature  ok"},
+		{[]string{"-origin", securelogintest.Origin}, valid + "\n", true, "provider   ok"},
+		{[]string{"-origin", securelogintest.Origin, expired}, "", false, "verify would fail with: expired token"},
+		{[]string{"-origin", "http://example.com", valid}, "", false, "only by scheme"},
+		{[]string{"-origin", securelogintest.Origin, "-scope", "access=all", valid}, "", false, "missing access"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			var out bytes.Buffer
+
+			ok, err := explain(c.args, strings.NewReader(c.stdin), &out)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if ok != c.ok {
+				t.Fatalf("Expected ok to be %t; got %t\n%s", c.ok, ok, &out)
+			}
+			if !strings.Contains(out.String(), c.out) {
+				t.Fatalf("Expected %q in\n%s", c.out, &out)
+			}
+		})
+	}
+}
+
+func TestExplainRequiresOrigin(t *testing.T) {
+	var out bytes.Buffer
+	if _, err := explain(nil, strings.NewReader("token"), &out); err == nil {
+		t.Fatalf("Expected error; got nil")
+	}
+}
+
+func TestExplainRejectsSeveralTokens(t *testing.T) {
+	var (
+		out   bytes.Buffer
+		token = string(securelogintest.New("homer@example.com").Encode())
+		i     = strings.Index(token, ",")
+	)
+
+	_, err := explain([]string{"-origin", securelogintest.Origin, token[:i], token[i:]}, strings.NewReader(""), &out)
+	if err == nil {
+		t.Fatalf("Expected error; got nil\n%s", &out)
+	}
+}
@@ -1,17 +1,22 @@
 package securelogin
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/url"
-	"strconv"
 	"strings"
 	"time"
 )
 
-var base64Decode = base64.StdEncoding.DecodeString
+var (
+	base64Decode = base64.StdEncoding.DecodeString
+
+	comma        = []byte(",")
+	escapedComma = []byte("%2C")
+)
 
 // Decoder reads and decodes sltoken from an input stream.
 type Decoder struct {
@@ -23,13 +28,29 @@ func NewDecoder(r io.Reader) *Decoder {
 	return &Decoder{r}
 }
 
-// Decode reads sltoken encoded data and returns a Token.
+// Decode reads sltoken encoded data and returns a Token. Data in the compact
+// binary encoding, or its base45 text form, is recognized and decoded as by
+// UnmarshalBinary.
 func (dec *Decoder) Decode(t *Token) error {
 	all, err := ioutil.ReadAll(dec.r)
 	if err != nil {
 		return err
 	}
 
+	if len(all) > 0 && all[0] == binaryMagic {
+		*t, err = UnmarshalBinary(all)
+		return err
+	}
+
+	if text := bytes.TrimSpace(all); isBase45(text) {
+		data, err := DecodeBase45(string(text))
+		if err != nil {
+			return wrap("base45", err)
+		}
+		*t, err = UnmarshalBinary(data)
+		return err
+	}
+
 	*t, err = Unmarshal(all)
 	return err
 }
@@ -40,35 +61,37 @@ func Unmarshal(data []byte) (Token, error) {
 }
 
 // UnmarshalString parses given string and constructs a Token from it or fails
-// with an error.
+// with an error. Both the standard and the URL-safe form are accepted, see
+// MarshalURL.
 func UnmarshalString(s string) (Token, error) {
 	var t Token
-	data, err := unescapeSplit(s, 4)
-	if err != nil {
-		return t, wrap("token", err)
-	}
 
-	t.rawPayload = []byte(data[0])
-	t.Email = data[3]
+	if isURLSafe(s) {
+		return unmarshalURL(s)
+	}
 
-	// payload
-	payload, err := unescapeSplit(data[0], 4)
+	version, rest, err := parseVersion([]byte(s))
 	if err != nil {
-		return t, wrap("payload", err)
+		return t, wrap("version", err)
 	}
+	t.Version = version
 
-	t.Provider = payload[0]
-	t.Client = payload[1]
-	t.Scope, err = url.ParseQuery(payload[2])
+	data, err := unescapeSplit(string(rest), 4)
 	if err != nil {
-		return t, wrap("payload", "parsing scope failed")
+		return t, wrap("token", err)
 	}
 
-	expire, err := strconv.ParseInt(payload[3], 10, 64)
+	t.rawPayload = []byte(data[0])
+	t.Email = data[3]
+
+	payload, err := parsePayload(data[0])
 	if err != nil {
-		return t, wrap("payload", "invalid expire time")
+		return t, err
 	}
-	t.ExpireAt = time.Unix(expire, 0)
+	t.Provider = payload.Provider
+	t.Client = payload.Client
+	t.Scope = payload.Scope
+	t.ExpireAt = payload.ExpireAt
 
 	// signatures
 	signatures, err := decodeKeys(data[1])
@@ -84,7 +107,7 @@ func UnmarshalString(s string) (Token, error) {
 		return t, wrap("keys", err)
 	}
 	t.PublicKey = keys[0]
-	t.HMACSecret = keys[1]
+	t.HMACSecret = newSecret(keys[1])
 
 	return t, nil
 }
@@ -126,3 +149,200 @@ func decodeKeys(s string) ([2][]byte, error) {
 func wrap(what string, err interface{}) error {
 	return fmt.Errorf("token unmarshal failed: in %s %s", what, err)
 }
+
+// UnmarshalInto parses encoded sltoken into t, reusing the buffers t already
+// holds. It accepts exactly what Unmarshal accepts and fails with the same
+// errors, but scans data only once and, apart from the HMAC secret, does
+// not allocate when t was previously filled by a token with the same
+// provider, client and email and the scope is empty, which is the case with
+// repeated sign-ins.
+//
+// The HMAC secret t held is left intact for copies of t still using it. Call
+// Token.Wipe first to clear it.
+//
+// On error t is left partially filled.
+func UnmarshalInto(data []byte, t *Token) error {
+	if bytes.HasPrefix(data, []byte(URLPrefix)) && !bytes.Contains(data, comma) {
+		tok, err := unmarshalURL(string(data))
+		*t = tok
+		return err
+	}
+
+	var err error
+	if t.Version, data, err = parseVersion(data); err != nil {
+		return wrap("version", err)
+	}
+
+	var fields [4][]byte
+	if n := splitBytes(data, comma, fields[:]); n != len(fields) {
+		return wrap("token", countError(len(fields), n))
+	}
+
+	// Fields are separated by a bare comma at top level and by an escaped
+	// one inside the payload, the signatures and the keys. Splitting on
+	// "%2C" is equivalent to the unescape-then-split done by Unmarshal.
+	var payload [4][]byte
+	n := splitBytes(fields[0], escapedComma, payload[:])
+
+	t.rawPayload = appendUnescaped(t.rawPayload[:0], fields[0])
+	setString(&t.Email, fields[3])
+
+	if n != len(payload) {
+		return wrap("payload", countError(len(payload), n))
+	}
+
+	setString(&t.Provider, payload[0])
+	setString(&t.Client, payload[1])
+	if err := parseScopeInto(&t.Scope, payload[2]); err != nil {
+		return wrap("payload", "parsing scope failed")
+	}
+
+	expire, ok := parseInt64(payload[3])
+	if !ok {
+		return wrap("payload", "invalid expire time")
+	}
+	t.ExpireAt = time.Unix(expire, 0)
+
+	t.Signature, t.HMACSignature, err = decodeKeysInto(fields[1], t.Signature, t.HMACSignature)
+	if err != nil {
+		return wrap("signatures", err)
+	}
+
+	// The previous secret may still be held by copies of t, which share its
+	// buffer, so the new one always gets a buffer of its own.
+	var secret []byte
+	t.PublicKey, secret, err = decodeKeysInto(fields[2], t.PublicKey, nil)
+	t.HMACSecret = Secret{}
+	if len(secret) > 0 {
+		t.HMACSecret = newSecret(secret)
+	}
+	if err != nil {
+		return wrap("keys", err)
+	}
+
+	return nil
+}
+
+// splitBytes slices s around each sep into fields and returns how many
+// elements s consists of, which may be more than len(fields).
+func splitBytes(s, sep []byte, fields [][]byte) int {
+	n := 0
+	for {
+		i := bytes.Index(s, sep)
+		if i < 0 {
+			break
+		}
+		if n < len(fields) {
+			fields[n] = s[:i]
+		}
+		n++
+		s = s[i+len(sep):]
+	}
+	if n < len(fields) {
+		fields[n] = s
+	}
+	return n + 1
+}
+
+func countError(expected, got int) error {
+	return fmt.Errorf("expected %d elements, got %d", expected, got)
+}
+
+func appendUnescaped(dst, s []byte) []byte {
+	for {
+		i := bytes.Index(s, escapedComma)
+		if i < 0 {
+			return append(dst, s...)
+		}
+		dst = append(dst, s[:i]...)
+		dst = append(dst, ',')
+		s = s[i+3:]
+	}
+}
+
+// setString stores b into *s unless it already holds the same text.
+func setString(s *string, b []byte) {
+	if bytes.Contains(b, escapedComma) {
+		b = appendUnescaped(nil, b)
+	}
+	if *s != string(b) {
+		*s = string(b)
+	}
+}
+
+func parseScopeInto(scope *url.Values, b []byte) error {
+	if *scope == nil {
+		*scope = make(url.Values)
+	}
+	for k := range *scope {
+		delete(*scope, k)
+	}
+	if len(b) == 0 {
+		return nil
+	}
+
+	parsed, err := url.ParseQuery(string(b))
+	for k, v := range parsed {
+		(*scope)[k] = v
+	}
+	return err
+}
+
+// parseInt64 accepts the same input as strconv.ParseInt(s, 10, 64) without
+// converting b to a string.
+func parseInt64(b []byte) (int64, bool) {
+	neg := false
+	if len(b) > 0 && (b[0] == '+' || b[0] == '-') {
+		neg = b[0] == '-'
+		b = b[1:]
+	}
+	if len(b) == 0 {
+		return 0, false
+	}
+
+	var n uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		if n > (1<<63)/10 {
+			return 0, false
+		}
+		n = n*10 + uint64(c-'0')
+		if n > 1<<63 {
+			return 0, false
+		}
+	}
+
+	if neg {
+		return -int64(n), true
+	}
+	if n == 1<<63 {
+		return 0, false
+	}
+	return int64(n), true
+}
+
+func decodeKeysInto(s, dst0, dst1 []byte) ([]byte, []byte, error) {
+	var keys [2][]byte
+	if n := splitBytes(s, escapedComma, keys[:]); n != len(keys) {
+		return dst0, dst1, countError(len(keys), n)
+	}
+
+	dst0, err := base64DecodeInto(dst0, keys[0])
+	if err != nil {
+		return dst0, dst1, err
+	}
+
+	dst1, err = base64DecodeInto(dst1, keys[1])
+	return dst0, dst1, err
+}
+
+func base64DecodeInto(dst, src []byte) ([]byte, error) {
+	size := base64.StdEncoding.DecodedLen(len(src))
+	if cap(dst) < size {
+		dst = make([]byte, size)
+	}
+	n, err := base64.StdEncoding.Decode(dst[:size], src)
+	return dst[:n], err
+}
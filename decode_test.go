@@ -2,6 +2,8 @@ package securelogin
 
 import (
 	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -86,3 +88,78 @@ func compareErrors(t *testing.T, expected, actual error) {
 		}
 	}
 }
+
+func TestUnmarshalInto(t *testing.T) {
+	for i, c := range decodeCases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			var tok Token
+			err := UnmarshalInto([]byte(c.str), &tok)
+			compareErrors(t, c.err, err)
+		})
+	}
+}
+
+func TestUnmarshalIntoMatchesUnmarshal(t *testing.T) {
+	expected, err := Unmarshal([]byte(token))
+	fatal(t, err)
+
+	var tok Token
+	for i := 0; i < 2; i++ {
+		fatal(t, UnmarshalInto([]byte(token), &tok))
+		if !reflect.DeepEqual(expected, tok) {
+			t.Fatalf("Expected %#v; got %#v", expected, tok)
+		}
+	}
+}
+
+func TestUnmarshalIntoReusesToken(t *testing.T) {
+	scoped := strings.Replace(token, "%2C%2C", "%2Caccess=all%2C", 1)
+
+	var tok Token
+	fatal(t, UnmarshalInto([]byte(scoped), &tok))
+	if tok.Scope.Get("access") != "all" {
+		t.Fatalf("Expected scope access=all; got %v", tok.Scope)
+	}
+
+	fatal(t, UnmarshalInto([]byte(token), &tok))
+	if len(tok.Scope) != 0 {
+		t.Fatalf("Expected empty scope; got %v", tok.Scope)
+	}
+}
+
+func TestParseInt64(t *testing.T) {
+	for _, s := range []string{
+		"0", "1498731060", "-1", "+1", "", "-", "1a", " 1",
+		"9223372036854775807", "9223372036854775808",
+		"-9223372036854775808", "-9223372036854775809",
+	} {
+		expected, err := strconv.ParseInt(s, 10, 64)
+		got, ok := parseInt64([]byte(s))
+		if ok != (err == nil) || (ok && got != expected) {
+			t.Errorf("parseInt64(%q) = %d, %t; want %d, %v", s, got, ok, expected, err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	data := []byte(token)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Unmarshal(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalInto(b *testing.B) {
+	var (
+		data = []byte(token)
+		tok  Token
+	)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := UnmarshalInto(data, &tok); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
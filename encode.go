@@ -9,28 +9,84 @@ import (
 var base64Encode = base64.StdEncoding.EncodeToString
 
 type Encoder struct {
-	w io.Writer
+	w      io.Writer
+	secret bool
+	binary bool
+	base45 bool
+	url    bool
 }
 
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w}
+	return &Encoder{w: w}
+}
+
+// SetIncludeSecret specifies whether the HMAC secret of tokens should be
+// encoded, as MarshalWithSecret does. The default is false.
+func (e *Encoder) SetIncludeSecret(on bool) {
+	e.secret = on
+}
+
+// SetBinary specifies whether tokens should be encoded in the compact binary
+// encoding, see MarshalBinary. The default is false.
+func (e *Encoder) SetBinary(on bool) {
+	e.binary = on
+}
+
+// SetBase45 specifies whether tokens should be encoded in the base45 text
+// form of the binary encoding, see EncodeBase45. It takes precedence over
+// SetBinary. The default is false.
+func (e *Encoder) SetBase45(on bool) {
+	e.base45 = on
+}
+
+// SetURLSafe specifies whether tokens should be encoded in the URL-safe form,
+// see MarshalURL. It takes precedence over SetBinary and SetBase45. The
+// default is false.
+func (e *Encoder) SetURLSafe(on bool) {
+	e.url = on
 }
 
 func (e *Encoder) Encode(t Token) error {
-	data := Marshal(t)
+	var data []byte
+	if e.url {
+		data = []byte(encodeURL(appendBinary(nil, t, e.secret)))
+	} else if e.base45 {
+		data = []byte(EncodeBase45(appendBinary(nil, t, e.secret)))
+	} else if e.binary {
+		data = appendBinary(nil, t, e.secret)
+	} else {
+		data = []byte(marshal(t, e.secret))
+	}
 
 	_, err := e.w.Write(data)
 	return err
 }
 
-// Marshal returns encoded Token as defied by the spec.
+// Marshal returns encoded Token as defied by the spec. The HMAC secret is
+// left out, see MarshalWithSecret.
 func Marshal(t Token) []byte {
 	return []byte(MarshalString(t))
 }
 
-// MarshalString returns encoded Token as defied by the spec to string.
+// MarshalString returns encoded Token as defied by the spec to string. The
+// HMAC secret is left out, see MarshalWithSecret.
 func MarshalString(t Token) string {
-	return escapeJoin([]string{
+	return marshal(t, false)
+}
+
+// MarshalWithSecret returns encoded Token including its HMAC secret, as sent
+// by the client.
+func MarshalWithSecret(t Token) []byte {
+	return []byte(marshal(t, true))
+}
+
+func marshal(t Token, withSecret bool) string {
+	var secret string
+	if withSecret {
+		secret = base64Encode(t.HMACSecret.Bytes())
+	}
+
+	return versionPrefix(t.Version) + escapeJoin([]string{
 		string(t.rawPayload),
 		escapeJoin([]string{
 			base64Encode(t.Signature),
@@ -38,7 +94,7 @@ func MarshalString(t Token) string {
 		}),
 		escapeJoin([]string{
 			base64Encode(t.PublicKey),
-			base64Encode(t.HMACSecret),
+			secret,
 		}),
 		t.Email,
 	})
@@ -2,6 +2,7 @@ package securelogin
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -9,18 +10,43 @@ func TestMarshalUnmarshal(t *testing.T) {
 	unmarshalled, err := Unmarshal([]byte(token))
 	fatal(t, err)
 
-	mu := Marshal(unmarshalled)
+	mu := MarshalWithSecret(unmarshalled)
 	if token != string(mu) {
 		t.Errorf("Expected:\t%s\nGot:\t\t\t%s", token, mu)
 	}
 }
 
+func TestMarshalOmitsSecret(t *testing.T) {
+	unmarshalled, err := Unmarshal([]byte(token))
+	fatal(t, err)
+
+	secret := base64Encode(unmarshalled.HMACSecret.Bytes())
+	if m := MarshalString(unmarshalled); strings.Contains(m, secret) {
+		t.Fatalf("Expected secret to be left out of %s", m)
+	}
+
+	buf := new(bytes.Buffer)
+	fatal(t, NewEncoder(buf).Encode(unmarshalled))
+	if strings.Contains(buf.String(), secret) {
+		t.Fatalf("Expected secret to be left out of %s", buf)
+	}
+
+	// Without the secret, HMAC can only be verified against a known one.
+	omitted, err := Unmarshal(Marshal(unmarshalled))
+	fatal(t, err)
+	if err := omitted.Verify(o, WithoutExpire, WithHMAC); err == nil {
+		t.Fatalf("Expected error; got nil")
+	}
+	fatal(t, omitted.Verify(o, WithoutExpire, WithHMAC, WithHMACSecret(unmarshalled.HMACSecret)))
+}
+
 func TestEncode(t *testing.T) {
 	unmarshalled, err := Unmarshal([]byte(token))
 	fatal(t, err)
 
 	buf := new(bytes.Buffer)
 	enc := NewEncoder(buf)
+	enc.SetIncludeSecret(true)
 	err = enc.Encode(unmarshalled)
 	fatal(t, err)
 
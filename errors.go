@@ -0,0 +1,68 @@
+package securelogin
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned by Verify for tokens that are authentic, but not accepted
+// by the configuration.
+var (
+	ErrInvalidProvider = errors.New("invalid provider")
+	ErrInvalidClient   = errors.New("invalid client")
+	ErrInvalidScope    = errors.New("invalid scope")
+	ErrExpired         = errors.New("expired token")
+)
+
+// SignatureError is returned by Verify when either the Ed25519 or the HMAC
+// signature of a token is invalid.
+type SignatureError struct {
+	// Fingerprint of the public key the token was verified against.
+	Fingerprint Fingerprint
+
+	// HMAC is set when the HMAC signature is invalid, as opposed to the
+	// Ed25519 one.
+	HMAC bool
+}
+
+func (e *SignatureError) Error() string {
+	if e.HMAC {
+		return "invalid HMAC signature"
+	}
+	return "invalid signature"
+}
+
+// VerifyError is returned by Verify when a check could not be completed,
+// e.g. because the context was canceled or a store lookup failed, as opposed
+// to the token being invalid.
+type VerifyError struct {
+	// Stage of the verification that failed, e.g. "signature" or "nonce".
+	Stage string
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *VerifyError) Error() string {
+	return "verify " + e.Stage + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// LifetimeError is returned by Verify when a token expires later than the
+// lifetime allowed for its scope by WithLifetime.
+type LifetimeError struct {
+	// Max is the lifetime allowed for the token.
+	Max time.Duration
+
+	// Remaining is how long until the token expires.
+	Remaining time.Duration
+}
+
+func (e *LifetimeError) Error() string {
+	return fmt.Sprintf("token lifetime %s exceeds %s", e.Remaining.Truncate(time.Second), e.Max)
+}
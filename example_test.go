@@ -77,6 +77,7 @@ func ExampleEncoder() {
 	}
 
 	enc := securelogin.NewEncoder(os.Stdout)
+	enc.SetIncludeSecret(true)
 	if err = enc.Encode(t); err != nil {
 		fmt.Printf("encode failed: %s", err)
 		return
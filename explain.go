@@ -0,0 +1,384 @@
+package securelogin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Check is the outcome of a single verification check in a Report.
+type Check struct {
+	// Name of the check, e.g. "signature" or "provider".
+	Name string
+
+	// Passed is set when the token passes the check.
+	Passed bool
+
+	// Skipped is set when the check is disabled by the configuration or
+	// can't be run by Explain. Skipped checks are neither passed nor failed.
+	Skipped bool
+
+	// Expected and Actual describe what the configuration accepts and what
+	// the token holds.
+	Expected string
+	Actual   string
+
+	// Err is the error Verify would return for this check.
+	Err error
+
+	// Hint is a likely cause of the failure, if any is known.
+	Hint string
+}
+
+// Report lists the outcome of every verification check of a token.
+type Report struct {
+	// Token the report is about. Zero if it failed to decode.
+	Token Token
+
+	// Checks in the order Verify runs them.
+	Checks []Check
+}
+
+// OK reports whether the token passes every check which was run.
+func (r Report) OK() bool {
+	return len(r.Failed()) == 0
+}
+
+// Failed returns the checks the token doesn't pass.
+func (r Report) Failed() []Check {
+	var failed []Check
+	for _, c := range r.Checks {
+		if !c.Passed && !c.Skipped {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// Err returns the error Verify would return, i.e. the one of the first failed
+// check, or nil.
+func (r Report) Err() error {
+	if failed := r.Failed(); len(failed) > 0 {
+		return failed[0].Err
+	}
+	return nil
+}
+
+// String formats the report as a table, one check per line.
+func (r Report) String() string {
+	var buf bytes.Buffer
+
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tRESULT\tEXPECTED\tACTUAL\tHINT")
+	for _, c := range r.Checks {
+		result := "ok"
+		switch {
+		case c.Skipped:
+			result = "skipped"
+		case !c.Passed:
+			result = "FAIL"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Name, result, c.Expected, c.Actual, c.Hint)
+	}
+	w.Flush()
+
+	return buf.String()
+}
+
+// Explain decodes token and explains its verification, see Token.Explain.
+// Decoding errors are reported as a failed "decode" check.
+func Explain(token []byte, opts ...Option) Report {
+	t, err := Unmarshal(token)
+	if err != nil {
+		return Report{Checks: []Check{{Name: "decode", Err: err}}}
+	}
+	return t.Explain(opts...)
+}
+
+// Explain runs every check Verify does with given options, without stopping
+// at the first failure, and reports the outcome of each along with hints on
+// why it failed. It's meant for debugging integrations; use Verify to accept
+// tokens.
+//
+// Nonces are not taken from their store, as that would consume them, so the
+// "nonce" check is skipped when WithNonce is used.
+func (t Token) Explain(opts ...Option) Report {
+	var (
+		cfg    = NewConfig(opts...)
+		now    = time.Now().UTC()
+		report = Report{Token: t}
+	)
+
+	add := func(c Check) {
+		c.Passed = !c.Skipped && c.Err == nil
+		report.Checks = append(report.Checks, c)
+	}
+
+	algs, err := lookupVersion(t.Version)
+	add(Check{Name: "version", Actual: fmt.Sprint(t.Version), Err: err})
+	if err != nil {
+		return report
+	}
+	message := t.signedMessage()
+
+	add(explainSignature(cfg, t, algs, message, now))
+	add(explainHMAC(cfg, t, algs, message, now))
+
+	add(Check{
+		Name:     "provider",
+		Expected: originList(cfg),
+		Actual:   t.Provider,
+		Err:      errIf(!cfg.hasOrigin(t.Provider), ErrInvalidProvider),
+		Hint:     originHint(cfg, t.Provider),
+	})
+
+	client := Check{Name: "client", Expected: originList(cfg), Actual: t.Client}
+	switch {
+	case cfg.connect:
+		client.Skipped = true
+		client.Expected = "any (Connect)"
+	case !cfg.hasOrigin(t.Client):
+		client.Err = ErrInvalidClient
+		client.Hint = originHint(cfg, t.Client)
+		if client.Hint == "" && cfg.hasOrigin(t.Provider) {
+			client.Hint = "client differs from provider; use WithConnect for Connect requests"
+		}
+	}
+	add(client)
+
+	expire := Check{
+		Name:     "expire",
+		Expected: "after " + now.Format(time.RFC3339),
+		Actual:   t.ExpireAt.UTC().Format(time.RFC3339),
+		Skipped:  !cfg.expire,
+	}
+	if cfg.expire && now.After(t.ExpireAt) {
+		ago := now.Sub(t.ExpireAt).Truncate(time.Second)
+		expire.Err = ErrExpired
+		expire.Hint = fmt.Sprintf("expired %s ago", ago)
+		if ago < time.Minute {
+			expire.Hint += "; check for clock skew"
+		}
+	}
+	add(expire)
+
+	lifetime := Check{
+		Name:    "lifetime",
+		Actual:  t.ExpireAt.Sub(now).Truncate(time.Second).String(),
+		Skipped: !cfg.expire || len(cfg.lifetimes) == 0,
+	}
+	if !lifetime.Skipped {
+		lifetime.Err = verifyLifetime(cfg, t, now)
+		var lerr *LifetimeError
+		if errors.As(lifetime.Err, &lerr) {
+			lifetime.Expected = "at most " + lerr.Max.String()
+			lifetime.Hint = "token was issued in the future or with a too long expiration"
+		}
+	}
+	add(lifetime)
+
+	add(explainScope(cfg, t))
+
+	add(Check{
+		Name:    "nonce",
+		Actual:  t.Scope.Get(NonceKey),
+		Skipped: true,
+		Hint:    nonceHint(cfg),
+	})
+
+	return report
+}
+
+func explainSignature(cfg Config, t Token, algs Algorithms, message []byte, now time.Time) Check {
+	var (
+		own        = t.PublicKey
+		overridden = len(cfg.publicKey) > 0 || len(cfg.publicKeys) > 0
+	)
+	if len(cfg.publicKey) > 0 {
+		t.PublicKey = cfg.publicKey
+	}
+
+	c := Check{
+		Name:     "signature",
+		Expected: "signed by " + t.Fingerprint().Short(),
+		Actual:   "carries " + NewFingerprint(own).Short(),
+	}
+	if len(cfg.publicKeys) > 0 {
+		fingerprints := make([]string, len(cfg.publicKeys))
+		for i, k := range cfg.publicKeys {
+			fingerprints[i] = NewFingerprint(k.Key).Short()
+		}
+		c.Expected = "signed by one of " + strings.Join(fingerprints, ", ")
+	}
+
+	if _, c.Err = t.checkSignature(cfg, algs, message, now); c.Err == nil {
+		return c
+	}
+
+	switch {
+	case overridden && algs.Signature.Verify(own, message, t.Signature):
+		c.Hint = "signed by the key in the token instead of the configured one; the user may have changed their key"
+	case len(cfg.publicKeys) == 0 && len(t.PublicKey) != publicKeySize:
+		c.Hint = fmt.Sprintf("public key is %d bytes, expected %d", len(t.PublicKey), publicKeySize)
+	default:
+		c.Hint = "payload or signature was altered"
+	}
+	return c
+}
+
+func explainHMAC(cfg Config, t Token, algs Algorithms, message []byte, now time.Time) Check {
+	c := Check{Name: "hmac", Skipped: !cfg.hmac}
+	if c.Skipped {
+		return c
+	}
+
+	if _, c.Err = t.checkHMAC(cfg, algs, message, now); c.Err == nil {
+		return c
+	}
+
+	overridden := cfg.hmacSecret.Len() > 0 || len(cfg.secrets) > 0
+	if overridden && verifyMAC(algs.MAC, message, t.HMACSignature, t.HMACSecret.Bytes()) {
+		c.Hint = "signed by the secret in the token instead of the configured one"
+	} else {
+		c.Hint = "payload or HMAC signature was altered"
+	}
+	return c
+}
+
+func explainScope(cfg Config, t Token) Check {
+	c := Check{
+		Name:     "scope",
+		Expected: withoutNonce(cfg.scope).Encode(),
+		Actual:   t.Scope.Encode(),
+	}
+	if cfg.mode != "" {
+		c.Expected = ModeKey + "=" + cfg.mode
+	}
+
+	var mode string
+	if mode, c.Err = verifyScope(cfg, t.Scope); c.Err != nil {
+		c.Hint = scopeHint(cfg, t.Scope)
+	} else if mode != "" {
+		c.Expected = ModeKey + "=" + mode
+	}
+	return c
+}
+
+// scopeHint tells how scope differs from the one expected by cfg.
+func scopeHint(cfg Config, scope url.Values) string {
+	if cfg.nonceStore != nil {
+		scope = withoutNonce(scope)
+	}
+
+	if mode := scope.Get(ModeKey); mode != "" && cfg.mode == "" {
+		if _, allowed := cfg.modes[mode]; allowed {
+			return ""
+		}
+		if _, err := lookupMode(mode); err == nil {
+			return fmt.Sprintf("token is in mode %q; use WithModes or WithChange to accept it", mode)
+		}
+	}
+	if _, ok := scope[NonceKey]; ok && cfg.nonceStore == nil {
+		return "token has a nonce; use WithNonce to accept it"
+	}
+
+	var missing, extra []string
+	for k := range withoutNonce(cfg.scope) {
+		if _, ok := scope[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	for k := range scope {
+		if _, ok := cfg.scope[k]; !ok {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+
+	var hints []string
+	if len(missing) > 0 {
+		hints = append(hints, "missing "+strings.Join(missing, ", "))
+	}
+	if len(extra) > 0 {
+		hints = append(hints, "unexpected "+strings.Join(extra, ", "))
+	}
+	if len(hints) == 0 && !scopesMatch(withoutNonce(scope), withoutNonce(cfg.scope)) {
+		hints = append(hints, "values differ")
+	}
+	return strings.Join(hints, "; ")
+}
+
+func nonceHint(cfg Config) string {
+	if cfg.nonceStore == nil {
+		return "WithNonce is not used"
+	}
+	return "not taken by Explain, as that would consume it"
+}
+
+func originList(cfg Config) string {
+	return strings.Join(sortedOrigins(cfg), ", ")
+}
+
+func sortedOrigins(cfg Config) []string {
+	origins := make([]string, 0, len(cfg.origins))
+	for o := range cfg.origins {
+		origins = append(origins, o)
+	}
+	sort.Strings(origins)
+	return origins
+}
+
+// originHint tells how origin differs from an allowed one it's almost equal
+// to, if any.
+func originHint(cfg Config, origin string) string {
+	if cfg.hasOrigin(origin) {
+		return ""
+	}
+	if len(cfg.origins) == 0 {
+		return "no origins are configured; use WithOrigins"
+	}
+
+	for _, allowed := range sortedOrigins(cfg) {
+		switch {
+		case strings.TrimSuffix(origin, "/") == strings.TrimSuffix(allowed, "/"):
+			return "differs from " + allowed + " only by trailing slash"
+		case strings.EqualFold(origin, allowed):
+			return "differs from " + allowed + " only by case"
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil {
+			continue
+		}
+		a, err := url.Parse(allowed)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case u.Scheme != a.Scheme && u.Host == a.Host:
+			return "differs from " + allowed + " only by scheme"
+		case u.Scheme == a.Scheme && u.Hostname() == a.Hostname() && u.Port() != a.Port() && isDefaultPort(u) && isDefaultPort(a):
+			return "differs from " + allowed + " only by default port"
+		}
+	}
+	return ""
+}
+
+func isDefaultPort(u *url.URL) bool {
+	port := u.Port()
+	return port == "" || u.Scheme == "https" && port == "443" || u.Scheme == "http" && port == "80"
+}
+
+func errIf(cond bool, err error) error {
+	if cond {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package securelogin
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func failedChecks(r Report) []string {
+	var names []string
+	for _, c := range r.Failed() {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestExplain(t *testing.T) {
+	var cases = []struct {
+		opt    []Option
+		mod    tokmod
+		failed []string
+		hint   string
+	}{
+		{[]Option{o}, tokAlive, nil, ""},
+		{[]Option{o}, tokExpired, []string{"expire"}, "expired 1h0m0s ago"},
+		{[]Option{o}, tokInvalidSignature, []string{"signature", "expire"}, "payload or signature was altered"},
+		{[]Option{o}, tokSmallPublicKey, []string{"signature", "expire"}, "public key is 30 bytes, expected 32"},
+		{[]Option{o, WithPublicKey(make([]byte, 32))}, tokAlive, []string{"signature"}, "the user may have changed their key"},
+		{[]Option{o, WithHMAC, WithSecret([]byte("wrong"))}, tokAlive, []string{"hmac"}, "instead of the configured one"},
+		{[]Option{o, WithHMAC}, tokInvalidHMAC, []string{"hmac", "expire"}, "HMAC signature was altered"},
+		{[]Option{o}, tokInvalidProvider, []string{"provider", "expire"}, ""},
+		{[]Option{o}, tokInvalidClient, []string{"client"}, "use WithConnect"},
+		{[]Option{WithOrigins("https://cobased.com/")}, tokAlive, []string{"provider", "client"}, "only by trailing slash"},
+		{[]Option{WithOrigins("http://cobased.com")}, tokAlive, []string{"provider", "client"}, "only by scheme"},
+		{[]Option{WithOrigins("https://Cobased.com")}, tokAlive, []string{"provider", "client"}, "only by case"},
+		{[]Option{WithOrigins("https://cobased.com:443")}, tokAlive, []string{"provider", "client"}, "only by default port"},
+		{nil, tokAlive, []string{"provider", "client"}, "use WithOrigins"},
+		{[]Option{o, WithConnect}, tokInvalidClient, nil, ""},
+		{[]Option{o, WithoutExpire}, tokExpired, nil, ""},
+		{[]Option{o}, tokScopeChange(changeScope), []string{"scope"}, `token is in mode "change"`},
+		{[]Option{o, WithScope(accessAllScope)}, tokAlive, []string{"scope"}, "missing access"},
+		{[]Option{o}, tokScopeChange(accessAllScope), []string{"scope"}, "unexpected access"},
+		{[]Option{o, WithScope(url.Values{"access": {"none"}})}, tokScopeChange(accessAllScope), []string{"scope"}, "values differ"},
+		{[]Option{o}, tokScopeChange(url.Values{NonceKey: {"x"}}), []string{"scope"}, "use WithNonce"},
+		{[]Option{o, WithMaxLifetime(1)}, tokAlive, []string{"lifetime"}, "issued in the future"},
+	}
+
+	token, err := UnmarshalString(token)
+	fatal(t, err)
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			token := c.mod(token)
+			r := token.Explain(c.opt...)
+
+			if got := failedChecks(r); !reflect.DeepEqual(got, c.failed) {
+				t.Fatalf("Expected failed checks %v; got %v\n%s", c.failed, got, r)
+			}
+			if r.OK() != (len(c.failed) == 0) {
+				t.Fatalf("Expected OK() to be %t", len(c.failed) == 0)
+			}
+
+			if c.hint != "" && !strings.Contains(r.String(), c.hint) {
+				t.Fatalf("Expected hint %q in\n%s", c.hint, r)
+			}
+
+			// The first failed check is what Verify fails with.
+			verr := token.Verify(c.opt...)
+			if fmt.Sprint(verr) != fmt.Sprint(r.Err()) {
+				t.Fatalf("Expected error %v; got %v", verr, r.Err())
+			}
+		})
+	}
+}
+
+func TestOriginHintSeparator(t *testing.T) {
+	cfg := NewConfig(WithOrigins("https://cobased.com/, https://other.com"))
+	if hint := originHint(cfg, "https://cobased.com"); hint != "" {
+		t.Fatalf("Expected no hint; got %q", hint)
+	}
+}
+
+func TestExplainSkipsNonce(t *testing.T) {
+	token, err := UnmarshalString(token)
+	fatal(t, err)
+
+	store := NewMemoryNonceStore()
+	r := tokScopeChange(url.Values{NonceKey: {"x"}})(token).Explain(o, WithNonce(store, ""))
+	for _, c := range r.Checks {
+		if c.Name == "nonce" && !c.Skipped {
+			t.Fatalf("Expected nonce check to be skipped")
+		}
+	}
+}
+
+func TestExplainDecodeError(t *testing.T) {
+	r := Explain([]byte("garbage"), o)
+	if r.OK() || len(r.Checks) != 1 || r.Checks[0].Name != "decode" {
+		t.Fatalf("Expected a failed decode check; got\n%s", r)
+	}
+	if r.Err() == nil {
+		t.Fatalf("Expected error; got nil")
+	}
+}
@@ -0,0 +1,112 @@
+package securelogin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Fingerprint is the SHA-256 hash of an Ed25519 public key. It's stable for
+// the lifetime of the key, so it can be used to identify it in storage, logs
+// and when comparing keys with the user.
+type Fingerprint [sha256.Size]byte
+
+// NewFingerprint returns the fingerprint of pubkey.
+func NewFingerprint(pubkey []byte) Fingerprint {
+	return Fingerprint(sha256.Sum256(pubkey))
+}
+
+// ParseFingerprint parses the hex form of a fingerprint, as returned by
+// String. Letter case, colons, dashes and spaces are ignored.
+func ParseFingerprint(s string) (Fingerprint, error) {
+	var f Fingerprint
+
+	s = stripSeparators(s)
+	if hex.DecodedLen(len(s)) != len(f) {
+		return f, fmt.Errorf("fingerprint: expected %d hex digits, got %d", hex.EncodedLen(len(f)), len(s))
+	}
+
+	if _, err := hex.Decode(f[:], []byte(s)); err != nil {
+		return f, fmt.Errorf("fingerprint: %s", err)
+	}
+	return f, nil
+}
+
+// String returns the fingerprint as lowercase hex.
+func (f Fingerprint) String() string {
+	return hex.EncodeToString(f[:])
+}
+
+// Short returns the first 8 bytes of the fingerprint as four dash separated
+// hex groups, e.g. "91d8-e371-cd3c-6012". It is meant for display and for
+// reading over the phone, not as a unique key.
+func (f Fingerprint) Short() string {
+	s := hex.EncodeToString(f[:8])
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16]
+}
+
+// Emoji returns the first 48 bits of the fingerprint as eight emoji, which
+// are easier to compare by eye than hex digits.
+func (f Fingerprint) Emoji() string {
+	var (
+		b    strings.Builder
+		bits = uint64(f[0])<<40 | uint64(f[1])<<32 | uint64(f[2])<<24 |
+			uint64(f[3])<<16 | uint64(f[4])<<8 | uint64(f[5])
+	)
+
+	for shift := 42; shift >= 0; shift -= 6 {
+		b.WriteString(emoji[bits>>uint(shift)&0x3F])
+	}
+	return b.String()
+}
+
+// Match reports whether s is any of the textual forms of f: the full hex
+// form, the short form or the emoji form.
+func (f Fingerprint) Match(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == f.Emoji() {
+		return true
+	}
+
+	s = strings.ToLower(stripSeparators(s))
+	return s == f.String() || s == stripSeparators(f.Short())
+}
+
+// IsZero reports whether f is the zero value, as opposed to a fingerprint of
+// any key.
+func (f Fingerprint) IsZero() bool {
+	return f == Fingerprint{}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (f Fingerprint) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (f *Fingerprint) UnmarshalText(text []byte) error {
+	parsed, err := ParseFingerprint(string(text))
+	if err != nil {
+		return err
+	}
+	*f = parsed
+	return nil
+}
+
+func stripSeparators(s string) string {
+	return strings.NewReplacer(":", "", "-", "", " ", "").Replace(s)
+}
+
+// emoji used by Fingerprint.Emoji. Each one is a single code point and is
+// easy to name when spoken. Do not reorder, the emoji form depends on it.
+var emoji = [64]string{
+	"🐶", "🐱", "🐭", "🐹", "🐰", "🦊", "🐻", "🐼",
+	"🐨", "🐯", "🦁", "🐮", "🐷", "🐸", "🐵", "🐔",
+	"🐧", "🐦", "🦆", "🦅", "🦉", "🐺", "🐗", "🐴",
+	"🦄", "🐝", "🐛", "🦋", "🐌", "🐞", "🐢", "🐍",
+	"🦎", "🐙", "🦑", "🦀", "🐡", "🐠", "🐬", "🐳",
+	"🦈", "🐊", "🐘", "🦒", "🐪", "🐄", "🌵", "🌲",
+	"🍄", "🌻", "🌙", "🔥", "🌈", "🍎", "🍋", "🍌",
+	"🍉", "🍇", "🍓", "🍒", "🍍", "🥕", "🌽", "🎈",
+}
@@ -0,0 +1,116 @@
+package securelogin
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFingerprint(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	f := tok.Fingerprint()
+	if f != NewFingerprint(tok.PublicKey) {
+		t.Fatalf("Expected token fingerprint to match its public key")
+	}
+
+	if len(f.String()) != 64 {
+		t.Fatalf("Expected 64 hex digits; got %q", f)
+	}
+
+	if !strings.HasPrefix(f.String(), strings.Replace(f.Short(), "-", "", -1)) {
+		t.Fatalf("Expected short form %q to be a prefix of %q", f.Short(), f)
+	}
+
+	if n := utf8.RuneCountInString(f.Emoji()); n != 8 {
+		t.Fatalf("Expected 8 emoji; got %d in %q", n, f.Emoji())
+	}
+}
+
+func TestFingerprintMatch(t *testing.T) {
+	f := NewFingerprint([]byte("key"))
+	other := NewFingerprint([]byte("other key"))
+
+	for _, s := range []string{
+		f.String(),
+		strings.ToUpper(f.String()),
+		f.Short(),
+		strings.Replace(f.Short(), "-", " ", -1),
+		" " + f.Emoji() + " ",
+	} {
+		if !f.Match(s) {
+			t.Errorf("Expected %q to match", s)
+		}
+		if other.Match(s) {
+			t.Errorf("Expected %q not to match other fingerprint", s)
+		}
+	}
+
+	if f.Match("") || f.Match(f.Short()[:4]) {
+		t.Errorf("Expected partial forms not to match")
+	}
+}
+
+func TestParseFingerprint(t *testing.T) {
+	f := NewFingerprint([]byte("key"))
+
+	parsed, err := ParseFingerprint(strings.ToUpper(f.String()))
+	fatal(t, err)
+	if parsed != f {
+		t.Fatalf("Expected %s; got %s", f, parsed)
+	}
+
+	for _, s := range []string{"", f.Short(), f.String()[1:] + "x"} {
+		if _, err := ParseFingerprint(s); err == nil {
+			t.Errorf("Expected error parsing %q", s)
+		}
+	}
+}
+
+func TestFingerprintText(t *testing.T) {
+	f := NewFingerprint([]byte("key"))
+
+	data, err := json.Marshal(map[Fingerprint]Fingerprint{f: f})
+	fatal(t, err)
+
+	var m map[Fingerprint]Fingerprint
+	fatal(t, json.Unmarshal(data, &m))
+	if m[f] != f {
+		t.Fatalf("Expected fingerprint to survive JSON round trip; got %s", data)
+	}
+}
+
+func TestFingerprintEmojiTable(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, e := range emoji {
+		if utf8.RuneCountInString(e) != 1 {
+			t.Errorf("Expected a single code point; got %q", e)
+		}
+		if seen[e] {
+			t.Errorf("Duplicated emoji %q", e)
+		}
+		seen[e] = true
+	}
+}
+
+func TestSignatureErrorFingerprint(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	var sigErr *SignatureError
+	err = tokInvalidSignature(tok).Verify(o, WithoutExpire)
+	if !errors.As(err, &sigErr) {
+		t.Fatalf("Expected *SignatureError; got %#v", err)
+	}
+	if sigErr.Fingerprint != tok.Fingerprint() || sigErr.HMAC {
+		t.Fatalf("Unexpected %#v", sigErr)
+	}
+
+	err = tokInvalidHMAC(tok).Verify(o, WithoutExpire, WithHMAC)
+	if !errors.As(err, &sigErr) || !sigErr.HMAC {
+		t.Fatalf("Expected HMAC *SignatureError; got %#v", err)
+	}
+}
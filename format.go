@@ -0,0 +1,120 @@
+package securelogin
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// redacted replaces secrets in formatted and logged tokens.
+const redacted = "[redacted]"
+
+// UnredactedToken formats and logs as a Token with all of its fields,
+// including signatures and the email, except for the HMAC secret which is a
+// Secret and thus always redacted. Convert a Token to it to opt in to full
+// output, e.g. while debugging:
+//
+//	log.Printf("%+v", securelogin.UnredactedToken(t))
+type UnredactedToken Token
+
+// String returns a summary of t which is safe to log. The public key is
+// shown by its fingerprint, the local part of the email is masked and the
+// signatures and the HMAC secret are left out.
+func (t Token) String() string {
+	var b strings.Builder
+
+	b.WriteString("sltoken{")
+	if t.Version > Version1 {
+		fmt.Fprintf(&b, "version=%d ", t.Version)
+	}
+	fmt.Fprintf(&b, "provider=%s client=%s", t.Provider, t.Client)
+	if len(t.Scope) > 0 {
+		fmt.Fprintf(&b, " scope=%s", t.Scope.Encode())
+	}
+	fmt.Fprintf(&b, " expire=%s email=%s key=%s}",
+		t.ExpireAt.UTC().Format(time.RFC3339), maskEmail(t.Email), t.Fingerprint().Short())
+
+	return b.String()
+}
+
+// GoString returns t in Go syntax like %#v would, with secrets replaced by
+// placeholders and the public key by its fingerprint.
+func (t Token) GoString() string {
+	return fmt.Sprintf("securelogin.Token{Version:%d, Provider:%q, Client:%q, Scope:%#v, "+
+		"ExpireAt:time.Unix(%d, 0), PublicKey:/* %s */, HMACSecret:%s, Signature:%s, HMACSignature:%s, Email:%q}",
+		t.Version, t.Provider, t.Client, t.Scope, t.ExpireAt.Unix(),
+		t.Fingerprint().Short(), redacted, redacted, redacted, maskEmail(t.Email))
+}
+
+// Format implements fmt.Formatter, so that secrets are redacted for every
+// verb: %#v formats as GoString and anything else as String.
+func (t Token) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('#'):
+		fmt.Fprint(f, t.GoString())
+	case verb == 'q':
+		fmt.Fprintf(f, "%q", t.String())
+	default:
+		fmt.Fprint(f, t.String())
+	}
+}
+
+// LogValue implements slog.LogValuer, logging t as a group of the fields
+// shown by String.
+func (t Token) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("provider", t.Provider),
+		slog.String("client", t.Client),
+		slog.Time("expire_at", t.ExpireAt),
+		slog.String("email", maskEmail(t.Email)),
+		slog.String("fingerprint", t.Fingerprint().Short()),
+	}
+	if t.Version > Version1 {
+		attrs = append(attrs, slog.Int("version", t.Version))
+	}
+	if len(t.Scope) > 0 {
+		attrs = append(attrs, slog.String("scope", t.Scope.Encode()))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Redact returns encoded token with its signatures and HMAC secret removed,
+// the public key replaced by its fingerprint and the email masked, so it can
+// be written to access logs. The payload is kept as is. Anything which does
+// not look like an encoded token is redacted entirely.
+func Redact(token string) string {
+	prefix := ""
+	if version, rest, err := parseVersion([]byte(token)); err == nil && version > Version1 {
+		prefix, token = versionPrefix(version), string(rest)
+	}
+
+	fields := strings.Split(token, ",")
+	if len(fields) != 4 {
+		return redacted
+	}
+
+	var fingerprint string
+	if keys, err := decodeKeys(strings.Replace(fields[2], "%2C", ",", -1)); err == nil {
+		fingerprint = NewFingerprint(keys[0]).Short()
+	} else {
+		fingerprint = redacted
+	}
+
+	return prefix + strings.Join([]string{fields[0], redacted, fingerprint, maskEmail(fields[3])}, ",")
+}
+
+// maskEmail keeps the first character of the local part and the domain of
+// email.
+func maskEmail(email string) string {
+	at := strings.LastIndexByte(email, '@')
+	if at <= 0 {
+		if email == "" {
+			return ""
+		}
+		return "***"
+	}
+	_, size := utf8.DecodeRuneInString(email)
+	return email[:size] + "***" + email[at:]
+}
@@ -0,0 +1,103 @@
+package securelogin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func secretsOf(tok Token) []string {
+	var secrets []string
+	for _, b := range [][]byte{tok.HMACSecret.Bytes(), tok.Signature, tok.HMACSignature} {
+		secrets = append(secrets, base64.StdEncoding.EncodeToString(b), fmt.Sprint(b), fmt.Sprintf("%x", b))
+	}
+	return append(secrets, tok.Email)
+}
+
+func TestFormatRedacts(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	var logged bytes.Buffer
+	slog.New(slog.NewTextHandler(&logged, nil)).Info("verified", "token", tok)
+
+	var outputs = []string{
+		tok.String(),
+		fmt.Sprint(tok),
+		fmt.Sprintf("%v", tok),
+		fmt.Sprintf("%+v", tok),
+		fmt.Sprintf("%#v", tok),
+		fmt.Sprintf("%s", tok),
+		fmt.Sprintf("%q", tok),
+		fmt.Sprintf("%x", tok),
+		fmt.Sprintf("%v", []Token{tok}),
+		fmt.Sprintf("%+v", Result{Token: tok}),
+		logged.String(),
+	}
+
+	for i, out := range outputs {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			for _, secret := range secretsOf(tok) {
+				if strings.Contains(out, secret) {
+					t.Fatalf("Expected %q to be redacted in %s", secret, out)
+				}
+			}
+			if !strings.Contains(out, tok.Fingerprint().Short()) {
+				t.Fatalf("Expected fingerprint in %s", out)
+			}
+		})
+	}
+}
+
+func TestFormatString(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	const expected = "sltoken{provider=https://cobased.com client=https://cobased.com " +
+		"expire=2017-06-29T10:11:00Z email=h***@gmail.com key=08c7-cc76-4325-d9a0}"
+	if got := tok.String(); got != expected {
+		fail(t, "string", expected, got)
+	}
+}
+
+func TestUnredactedToken(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	out := fmt.Sprintf("%+v", UnredactedToken(tok))
+	if !strings.Contains(out, fmt.Sprint(tok.Signature)) || !strings.Contains(out, tok.Email) {
+		t.Fatalf("Expected full output; got %s", out)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	var cases = []struct {
+		in, out string
+	}{
+		{token, "https://cobased.com%2Chttps://cobased.com%2C%2C1498731060,[redacted],08c7-cc76-4325-d9a0,h***@gmail.com"},
+		{"v2," + token, "v2,https://cobased.com%2Chttps://cobased.com%2C%2C1498731060,[redacted],08c7-cc76-4325-d9a0,h***@gmail.com"},
+		{"a,b,not base64,@example.com", "a,[redacted],[redacted],***"},
+		{"garbage", "[redacted]"},
+		{"", "[redacted]"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got := Redact(c.in)
+			if got != c.out {
+				fail(t, "redacted", c.out, got)
+			}
+			for _, secret := range secretsOf(tok) {
+				if strings.Contains(got, secret) {
+					t.Fatalf("Expected %q to be redacted in %s", secret, got)
+				}
+			}
+		})
+	}
+}
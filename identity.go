@@ -0,0 +1,137 @@
+package securelogin
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// emailDomain is idna.Lookup which also rejects empty and overlong labels.
+var emailDomain = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.VerifyDNSLength(true))
+
+// Identity identifies a user account. Two tokens belong to the same account
+// if and only if their identities are equal.
+type Identity struct {
+	// Email in its canonical form, see CanonicalEmail.
+	Email string
+
+	// Fingerprint of the public key the user signs tokens with.
+	Fingerprint Fingerprint
+}
+
+// NewIdentity returns the Identity of the user who signed t. The token is
+// expected to be already verified.
+func NewIdentity(t Token, rules ...EmailRule) (Identity, error) {
+	email, err := CanonicalEmail(t.Email, rules...)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	return Identity{Email: email, Fingerprint: t.Fingerprint()}, nil
+}
+
+// String returns the email and the short form of the fingerprint.
+func (id Identity) String() string {
+	return id.Email + " (" + id.Fingerprint.Short() + ")"
+}
+
+// EmailRule rewrites the local part and the domain of an email after the
+// domain is canonicalized. Rules are used for provider-specific
+// equivalences such as ignoring dots in Gmail addresses.
+type EmailRule func(local, domain string) (string, string)
+
+// CanonicalEmail validates email and returns its canonical form: surrounding
+// space is trimmed and the domain is converted to lowercase ASCII, using
+// IDNA for internationalized domains. The local part is kept as is, since it
+// may be case-sensitive, unless a rule says otherwise. Quoted local parts
+// are not supported.
+func CanonicalEmail(email string, rules ...EmailRule) (string, error) {
+	email = strings.TrimSpace(email)
+
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return "", emailError(email, "missing @")
+	}
+
+	local, domain := email[:at], strings.TrimSuffix(email[at+1:], ".")
+	if err := validateLocal(local); err != nil {
+		return "", emailError(email, err.Error())
+	}
+
+	domain, err := emailDomain.ToASCII(domain)
+	if err != nil || domain == "" {
+		return "", emailError(email, "invalid domain")
+	}
+
+	for _, rule := range rules {
+		local, domain = rule(local, domain)
+	}
+
+	email = local + "@" + domain
+	if len(email) > 254 {
+		return "", emailError(email, "too long")
+	}
+	return email, nil
+}
+
+// LowercaseLocal is an EmailRule which makes the local part case-insensitive.
+func LowercaseLocal(local, domain string) (string, string) {
+	return strings.ToLower(local), domain
+}
+
+// StripTag is an EmailRule which drops sub-addressing tags, so that
+// "user+tag@example.com" becomes "user@example.com".
+func StripTag(local, domain string) (string, string) {
+	if i := strings.IndexByte(local, '+'); i > 0 {
+		local = local[:i]
+	}
+	return local, domain
+}
+
+// Gmail is an EmailRule for addresses served by Gmail, which ignores case,
+// dots and tags in the local part and treats googlemail.com as gmail.com.
+func Gmail(local, domain string) (string, string) {
+	if domain != "gmail.com" && domain != "googlemail.com" {
+		return local, domain
+	}
+
+	local, _ = StripTag(local, domain)
+	local, _ = LowercaseLocal(local, domain)
+	return strings.Replace(local, ".", "", -1), "gmail.com"
+}
+
+// validateLocal accepts dot-atom local parts as defined by RFC 5322, along
+// with UTF-8 characters allowed by RFC 6531.
+func validateLocal(local string) error {
+	switch {
+	case local == "":
+		return fmt.Errorf("empty local part")
+	case len(local) > 64:
+		return fmt.Errorf("local part too long")
+	case !utf8.ValidString(local):
+		return fmt.Errorf("invalid UTF-8")
+	case local[0] == '.' || local[len(local)-1] == '.' || strings.Contains(local, ".."):
+		return fmt.Errorf("misplaced dot in local part")
+	}
+
+	for _, r := range local {
+		if r < utf8.RuneSelf && !isAtext(byte(r)) && r != '.' {
+			return fmt.Errorf("invalid character %q in local part", r)
+		}
+	}
+	return nil
+}
+
+func isAtext(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-/=?^_`{|}~", c) >= 0
+}
+
+func emailError(email, reason string) error {
+	return fmt.Errorf("invalid email %q: %s", email, reason)
+}
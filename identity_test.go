@@ -0,0 +1,79 @@
+package securelogin
+
+import "testing"
+
+func TestCanonicalEmail(t *testing.T) {
+	var cases = []struct {
+		email    string
+		rules    []EmailRule
+		expected string
+	}{
+		{"homakov@gmail.com", nil, "homakov@gmail.com"},
+		{" Homakov@Gmail.com ", nil, "Homakov@gmail.com"},
+		{"user@Example.COM.", nil, "user@example.com"},
+		{"user@bücher.example", nil, "user@xn--bcher-kva.example"},
+		{"user@BÜCHER.example", nil, "user@xn--bcher-kva.example"},
+		{"üser@example.com", nil, "üser@example.com"},
+		{"a!#$%&'*+-/=?^_`{|}~z@example.com", nil, "a!#$%&'*+-/=?^_`{|}~z@example.com"},
+		{"User+Tag@Example.com", []EmailRule{StripTag}, "User@example.com"},
+		{"User+Tag@Example.com", []EmailRule{LowercaseLocal}, "user+tag@example.com"},
+		{"Ho.Ma.Kov+spam@GoogleMail.com", []EmailRule{Gmail}, "homakov@gmail.com"},
+		{"Ho.Ma.Kov+spam@example.com", []EmailRule{Gmail}, "Ho.Ma.Kov+spam@example.com"},
+	}
+
+	for _, c := range cases {
+		got, err := CanonicalEmail(c.email, c.rules...)
+		if err != nil {
+			t.Errorf("CanonicalEmail(%q): unexpected error: %s", c.email, err)
+		} else if got != c.expected {
+			t.Errorf("CanonicalEmail(%q) = %q; want %q", c.email, got, c.expected)
+		}
+	}
+}
+
+func TestCanonicalEmailInvalid(t *testing.T) {
+	for _, email := range []string{
+		"",
+		"homakov",
+		"@gmail.com",
+		"homakov@",
+		".homakov@gmail.com",
+		"homakov.@gmail.com",
+		"ho..makov@gmail.com",
+		"ho makov@gmail.com",
+		"\"homakov\"@gmail.com",
+		"homakov@gm ail.com",
+		"homakov@-gmail.com",
+		"homakov@gmail..com",
+		"a1234567890123456789012345678901234567890123456789012345678901234@gmail.com",
+	} {
+		if got, err := CanonicalEmail(email); err == nil {
+			t.Errorf("CanonicalEmail(%q) = %q; expected error", email, got)
+		}
+	}
+}
+
+func TestNewIdentity(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	a, err := NewIdentity(tok)
+	fatal(t, err)
+
+	tok.Email = " HomaKov@GMAIL.com"
+	b, err := NewIdentity(tok, LowercaseLocal)
+	fatal(t, err)
+
+	if a != b {
+		t.Fatalf("Expected %s and %s to be equal", a, b)
+	}
+
+	if a.Fingerprint != tok.Fingerprint() {
+		t.Fatalf("Expected identity fingerprint to match the token's")
+	}
+
+	tok.Email = "nobody"
+	if _, err := NewIdentity(tok); err == nil {
+		t.Fatalf("Expected invalid email to fail")
+	}
+}
@@ -0,0 +1,62 @@
+package securelogin
+
+import "time"
+
+// TrustedKey is a public key or a HMAC secret accepted during verification
+// until NotAfter. Zero NotAfter means the key doesn't expire.
+type TrustedKey struct {
+	Key      []byte
+	NotAfter time.Time
+}
+
+// WithPublicKeys accepts tokens signed by any of keys instead of the public
+// key carried by the token, e.g. both the previous and the new key while
+// the user is changing it. It takes precedence over WithPublicKey.
+// Result.PublicKeyIndex reports which key matched.
+func WithPublicKeys(keys ...TrustedKey) Option {
+	return func(c *Config) { c.publicKeys = append(c.publicKeys, keys...) }
+}
+
+// WithSecrets accepts HMAC signatures made with any of secrets, when HMAC
+// verification is enabled. It takes precedence over WithSecret.
+// Result.SecretIndex reports which secret matched. The secrets are copied,
+// so the caller may wipe them afterwards.
+func WithSecrets(secrets ...TrustedKey) Option {
+	copied := make([]trustedSecret, len(secrets))
+	for i, s := range secrets {
+		copied[i] = trustedSecret{NewSecret(s.Key), s.NotAfter}
+	}
+	return func(c *Config) { c.secrets = append(c.secrets, copied...) }
+}
+
+// trustedSecret is a TrustedKey holding a HMAC secret.
+type trustedSecret struct {
+	secret   Secret
+	notAfter time.Time
+}
+
+// secretKeys returns secrets as TrustedKey for matchKey. The keys share the
+// material of the secrets.
+func secretKeys(secrets []trustedSecret) []TrustedKey {
+	keys := make([]TrustedKey, len(secrets))
+	for i, s := range secrets {
+		keys[i] = TrustedKey{Key: s.secret.Bytes(), NotAfter: s.notAfter}
+	}
+	return keys
+}
+
+// matchKey returns the index of the first key which is valid at now and
+// which verify accepts, or -1. All keys are tried regardless of earlier
+// matches and expiration, so the time taken doesn't depend on which one
+// matched.
+func matchKey(keys []TrustedKey, now time.Time, verify func(key []byte) bool) int {
+	match := -1
+	for i, k := range keys {
+		ok := verify(k.Key)
+		valid := k.NotAfter.IsZero() || !now.After(k.NotAfter)
+		if ok && valid && match < 0 {
+			match = i
+		}
+	}
+	return match
+}
@@ -0,0 +1,61 @@
+package securelogin
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTrustedKeys(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	var (
+		hour    = time.Hour
+		key     = func(k []byte, notAfter time.Duration) TrustedKey { return TrustedKey{k, time.Now().Add(notAfter)} }
+		current = TrustedKey{Key: tok.PublicKey}
+		secret  = TrustedKey{Key: tok.HMACSecret.Bytes()}
+		wrong   = TrustedKey{Key: make([]byte, publicKeySize)}
+	)
+
+	var cases = []struct {
+		opt         []Option
+		keyIndex    int
+		secretIndex int
+		err         string
+	}{
+		{[]Option{o}, -1, -1, ""},
+		{[]Option{o, WithPublicKeys(current)}, 0, -1, ""},
+		{[]Option{o, WithPublicKeys(wrong, current)}, 1, -1, ""},
+		{[]Option{o, WithPublicKeys(current, current)}, 0, -1, ""},
+		{[]Option{o, WithPublicKeys(key(tok.PublicKey, hour))}, 0, -1, ""},
+		{[]Option{o, WithPublicKeys(key(tok.PublicKey, -hour), wrong)}, -1, -1, "invalid signature"},
+		{[]Option{o, WithPublicKeys(wrong)}, -1, -1, "invalid signature"},
+		{[]Option{o, WithPublicKey(wrong.Key), WithPublicKeys(current)}, 0, -1, ""},
+		{[]Option{o, WithHMAC, WithSecrets(wrong, secret)}, -1, 1, ""},
+		{[]Option{o, WithHMAC, WithSecrets(key(tok.HMACSecret.Bytes(), hour))}, -1, 0, ""},
+		{[]Option{o, WithHMAC, WithSecrets(key(tok.HMACSecret.Bytes(), -hour))}, -1, -1, "invalid HMAC signature"},
+		{[]Option{o, WithHMAC, WithSecrets(wrong)}, -1, -1, "invalid HMAC signature"},
+		{[]Option{o, WithSecrets(wrong)}, -1, -1, ""},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			res, err := tokAlive(tok).VerifyResult(c.opt...)
+			if c.err == "" {
+				fatal(t, err)
+			} else if err == nil || err.Error() != c.err {
+				t.Fatalf("Expected error %q; got %v", c.err, err)
+			}
+
+			if res.PublicKeyIndex != c.keyIndex || res.SecretIndex != c.secretIndex {
+				t.Fatalf("Expected key %d and secret %d; got %d and %d",
+					c.keyIndex, c.secretIndex, res.PublicKeyIndex, res.SecretIndex)
+			}
+
+			if err == nil && res.Fingerprint != tok.Fingerprint() {
+				t.Fatalf("Expected fingerprint %s; got %s", tok.Fingerprint(), res.Fingerprint)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package securelogin
+
+import (
+	"net/url"
+	"time"
+)
+
+// lifetimeLeeway accounts for ExpireAt being rounded to seconds.
+const lifetimeLeeway = time.Second
+
+// lifetimeRule limits the lifetime of tokens with matching scope.
+type lifetimeRule struct {
+	scope url.Values
+	max   time.Duration
+	all   bool
+}
+
+// WithLifetime limits how far in the future tokens whose scope contains all
+// of given values may expire. Empty scope matches sign-in tokens, which have
+// empty scope. When several rules match, the strictest one applies.
+//
+// For example, sign-in tokens may be limited to five minutes and transfers
+// to a minute with:
+//
+//	WithLifetime(nil, 5*time.Minute)
+//	WithLifetime(url.Values{"action": {"transfer"}}, time.Minute)
+func WithLifetime(scope url.Values, max time.Duration) Option {
+	return func(c *Config) {
+		c.lifetimes = append(c.lifetimes, lifetimeRule{scope: scope, max: max})
+	}
+}
+
+// WithMaxLifetime limits the lifetime of all tokens, see WithLifetime.
+func WithMaxLifetime(max time.Duration) Option {
+	return func(c *Config) {
+		c.lifetimes = append(c.lifetimes, lifetimeRule{max: max, all: true})
+	}
+}
+
+func (r lifetimeRule) match(scope url.Values) bool {
+	switch {
+	case r.all:
+		return true
+	case len(r.scope) == 0:
+		return len(withoutNonce(scope)) == 0
+	}
+
+	for key, values := range r.scope {
+		for _, v := range values {
+			if !has(scope, key, v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// verifyLifetime rejects tokens which expire later than the strictest rule
+// matching their scope allows. In other words, tokens whose implied issue
+// time, ExpireAt minus the allowed lifetime, is in the future.
+func verifyLifetime(cfg Config, t Token, now time.Time) error {
+	var (
+		max     time.Duration
+		matched bool
+	)
+
+	for _, rule := range cfg.lifetimes {
+		if rule.match(t.Scope) && (!matched || rule.max < max) {
+			max, matched = rule.max, true
+		}
+	}
+
+	if remaining := t.ExpireAt.Sub(now); matched && remaining > max+lifetimeLeeway {
+		return &LifetimeError{Max: max, Remaining: remaining}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package securelogin
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func tokExpireIn(d time.Duration, scope url.Values) tokmod {
+	return func(t Token) Token {
+		t.ExpireAt = time.Now().Add(d)
+		t.Scope = scope
+		return t
+	}
+}
+
+func TestLifetime(t *testing.T) {
+	var (
+		transfer     = url.Values{"action": []string{"transfer"}, "amount": []string{"10"}}
+		transferOnly = url.Values{"action": []string{"transfer"}}
+		nonceOnly    = url.Values{NonceKey: []string{"nonce"}}
+		rules        = []Option{
+			o,
+			WithModes("change"),
+			WithScope(transfer),
+			WithLifetime(nil, 5*time.Minute),
+			WithLifetime(transferOnly, time.Minute),
+			WithLifetime(url.Values{"mode": []string{"change"}}, 2*time.Minute),
+		}
+	)
+
+	var cases = []struct {
+		opt []Option
+		mod tokmod
+		max time.Duration
+	}{
+		{[]Option{o}, tokExpireIn(365*24*time.Hour, nil), 0},
+		{rules, tokExpireIn(5*time.Minute, nil), 0},
+		{rules, tokExpireIn(6*time.Minute, nil), 5 * time.Minute},
+		{rules, tokExpireIn(time.Minute, transfer), 0},
+		{rules, tokExpireIn(2*time.Minute, transfer), time.Minute},
+		{rules, tokExpireIn(2*time.Minute, changeScope), 0},
+		{rules, tokExpireIn(3*time.Minute, changeScope), 2 * time.Minute},
+		{append(rules, WithMaxLifetime(30*time.Second)), tokExpireIn(time.Minute, transfer), 30 * time.Second},
+		{append(rules, WithoutExpire), tokExpireIn(time.Hour, transfer), 0},
+		{append(rules, WithNonce(NewMemoryNonceStore(), "")), tokExpireIn(6*time.Minute, nonceOnly), 5 * time.Minute},
+	}
+
+	token, err := UnmarshalString(token)
+	fatal(t, err)
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			err := c.mod(token).Verify(c.opt...)
+
+			var lifetimeErr *LifetimeError
+			if c.max == 0 {
+				if errors.As(err, &lifetimeErr) {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+				return
+			}
+
+			if !errors.As(err, &lifetimeErr) {
+				t.Fatalf("Expected *LifetimeError; got %v", err)
+			}
+			if lifetimeErr.Max != c.max {
+				t.Fatalf("Expected max lifetime %s; got %s", c.max, lifetimeErr.Max)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package securelogin
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// ModeKey is the scope key selecting the protocol mode of a token.
+const ModeKey = "mode"
+
+// Mode handles tokens whose scope carries mode=<name>, instead of matching
+// the scope against the one given by WithScope.
+type Mode interface {
+	// Validate checks the scope of a token in this mode.
+	Validate(scope url.Values) error
+
+	// Result extracts the mode specific result of a verified token. It is
+	// returned as Result.Value.
+	Result(t Token) (interface{}, error)
+}
+
+var modes = struct {
+	sync.RWMutex
+	m map[string]Mode
+}{m: map[string]Mode{"change": changeMode{}}}
+
+// RegisterMode makes a mode available by name to WithModes. It panics if
+// called twice with the same name or if mode is nil.
+func RegisterMode(name string, mode Mode) {
+	modes.Lock()
+	defer modes.Unlock()
+
+	if mode == nil {
+		panic("securelogin: RegisterMode mode is nil")
+	}
+	if _, dup := modes.m[name]; dup {
+		panic("securelogin: RegisterMode called twice for mode " + name)
+	}
+	modes.m[name] = mode
+}
+
+func lookupMode(name string) (Mode, error) {
+	modes.RLock()
+	defer modes.RUnlock()
+
+	mode, ok := modes.m[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mode %q", name)
+	}
+	return mode, nil
+}
+
+// ChangeResult is the Result.Value of tokens in "change" mode.
+type ChangeResult struct {
+	// To is where the account is requested to be moved.
+	To string
+}
+
+// PublicKey decodes To as a base64 encoded Ed25519 public key.
+func (c ChangeResult) PublicKey() ([]byte, error) {
+	key, err := base64Decode(c.To)
+	if err != nil {
+		return nil, fmt.Errorf("change: invalid public key: %s", err)
+	}
+	if len(key) != publicKeySize {
+		return nil, errors.New("change: invalid public key size")
+	}
+	return key, nil
+}
+
+type changeMode struct{}
+
+func (changeMode) Validate(scope url.Values) error {
+	_, hasTo := scope["to"]
+	if !(len(scope) == 2 && hasTo && has(scope, ModeKey, "change")) {
+		return errors.New("not mode=change token")
+	}
+	return nil
+}
+
+func (changeMode) Result(t Token) (interface{}, error) {
+	return ChangeResult{To: t.Scope.Get("to")}, nil
+}
+
+// scopeMode returns the mode the token scope is in, if it's one of allowed.
+func scopeMode(cfg Config, scope url.Values) string {
+	values := scope[ModeKey]
+	if len(values) != 1 {
+		return ""
+	}
+
+	if _, ok := cfg.modes[values[0]]; !ok {
+		return ""
+	}
+	return values[0]
+}
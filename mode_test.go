@@ -0,0 +1,122 @@
+package securelogin
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+type deleteAccount struct{ Reason string }
+
+type deleteAccountMode struct{}
+
+func (deleteAccountMode) Validate(scope url.Values) error {
+	if scope.Get("confirm") != "yes" {
+		return errors.New("delete-account not confirmed")
+	}
+	return nil
+}
+
+func (deleteAccountMode) Result(t Token) (interface{}, error) {
+	return deleteAccount{Reason: t.Scope.Get("reason")}, nil
+}
+
+type brokenMode struct{}
+
+func (brokenMode) Validate(url.Values) error         { return nil }
+func (brokenMode) Result(Token) (interface{}, error) { return nil, errors.New("broken") }
+
+func init() {
+	RegisterMode("delete-account", deleteAccountMode{})
+	RegisterMode("broken", brokenMode{})
+}
+
+func TestModes(t *testing.T) {
+	var (
+		deleteScope = url.Values{
+			"mode":    []string{"delete-account"},
+			"confirm": []string{"yes"},
+			"reason":  []string{"moving"},
+		}
+		unconfirmedScope = url.Values{"mode": []string{"delete-account"}}
+		ambiguousScope   = url.Values{"mode": []string{"delete-account", "change"}, "confirm": []string{"yes"}}
+		unknownScope     = url.Values{"mode": []string{"unknown"}}
+		brokenScope      = url.Values{"mode": []string{"broken"}}
+		modes            = WithModes("delete-account", "change", "unknown", "broken")
+	)
+
+	var cases = []struct {
+		opt   []Option
+		mod   tokmod
+		mode  string
+		value interface{}
+		err   string
+	}{
+		{[]Option{o, modes}, tokAlive, "", nil, ""},
+		{[]Option{o, modes}, tokScopeChange(deleteScope), "delete-account", deleteAccount{"moving"}, ""},
+		{[]Option{o, modes}, tokScopeChange(changeScope), "change", ChangeResult{"..."}, ""},
+		{[]Option{o, WithChange}, tokScopeChange(changeScope), "change", ChangeResult{"..."}, ""},
+		{[]Option{o, modes}, tokScopeChange(unconfirmedScope), "", nil, "delete-account not confirmed"},
+		{[]Option{o}, tokScopeChange(deleteScope), "", nil, "invalid scope"},
+		{[]Option{o, modes}, tokScopeChange(ambiguousScope), "", nil, "invalid scope"},
+		{[]Option{o, modes}, tokScopeChange(unknownScope), "", nil, `unknown mode "unknown"`},
+		{[]Option{o, modes}, tokScopeChange(brokenScope), "", nil, "broken"},
+		{[]Option{o, WithChange, modes}, tokScopeChange(deleteScope), "", nil, "not mode=change token"},
+	}
+
+	token, err := UnmarshalString(token)
+	fatal(t, err)
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			res, err := c.mod(token).VerifyResult(c.opt...)
+			if c.err == "" {
+				fatal(t, err)
+			} else if err == nil || err.Error() != c.err {
+				t.Fatalf("Expected error %q; got %v", c.err, err)
+			}
+
+			if err == nil && (res.Mode != c.mode || !reflect.DeepEqual(res.Value, c.value)) {
+				t.Fatalf("Expected mode %q with %#v; got %q with %#v", c.mode, c.value, res.Mode, res.Value)
+			}
+		})
+	}
+}
+
+func TestRegisterModePanics(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		mode Mode
+	}{
+		{"change", deleteAccountMode{}},
+		{"nil", nil},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected RegisterMode(%q) to panic", c.name)
+				}
+			}()
+			RegisterMode(c.name, c.mode)
+		}()
+	}
+}
+
+func TestChangeResultPublicKey(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	key, err := ChangeResult{To: base64Encode(tok.PublicKey)}.PublicKey()
+	fatal(t, err)
+	if !reflect.DeepEqual(key, tok.PublicKey) {
+		t.Fatalf("Expected %x; got %x", tok.PublicKey, key)
+	}
+
+	for _, to := range []string{"...", base64Encode(tok.PublicKey[1:])} {
+		if _, err := (ChangeResult{To: to}).PublicKey(); err == nil {
+			t.Errorf("Expected error for %q", to)
+		}
+	}
+}
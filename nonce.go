@@ -0,0 +1,161 @@
+package securelogin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// NonceKey is the scope key carrying the challenge issued by NewNonce.
+const NonceKey = "nonce"
+
+// ErrNonceNotFound is returned by NonceStore when a nonce was never issued
+// or was already taken.
+var ErrNonceNotFound = errors.New("nonce not found")
+
+// NonceStore keeps issued nonces on the server side. Implementations must be
+// safe for concurrent use.
+type NonceStore interface {
+	// Put stores nonce issued for session, valid until expireAt.
+	Put(ctx context.Context, nonce, session string, expireAt time.Time) error
+
+	// Take atomically removes nonce and returns what it was stored with, or
+	// ErrNonceNotFound. Concurrent calls with the same nonce must succeed
+	// at most once.
+	Take(ctx context.Context, nonce string) (session string, expireAt time.Time, err error)
+}
+
+// NewNonce issues a random single-use challenge for session, which expires
+// after ttl. The client is expected to include it in the token's scope under
+// NonceKey.
+func NewNonce(ctx context.Context, store NonceStore, session string, ttl time.Duration) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	nonce := base64.RawURLEncoding.EncodeToString(b[:])
+	return nonce, store.Put(ctx, nonce, session, time.Now().Add(ttl))
+}
+
+// WithNonce requires the token scope to carry a nonce issued for session by
+// NewNonce, which is then consumed. The nonce is ignored when matching the
+// rest of the scope.
+func WithNonce(store NonceStore, session string) Option {
+	return func(c *Config) {
+		c.nonceStore = store
+		c.nonceSession = session
+	}
+}
+
+// verifyNonce consumes the nonce in scope. It's the last check of Verify, so
+// that invalid tokens can't burn nonces.
+func verifyNonce(ctx context.Context, cfg Config, scope url.Values) error {
+	if cfg.nonceStore == nil {
+		return nil
+	}
+
+	values := scope[NonceKey]
+	if len(values) != 1 || values[0] == "" {
+		return errors.New("missing nonce")
+	}
+
+	session, expireAt, err := cfg.nonceStore.Take(ctx, values[0])
+	if errors.Is(err, ErrNonceNotFound) {
+		return errors.New("invalid nonce")
+	} else if err != nil {
+		return &VerifyError{Stage: "nonce", Err: err}
+	}
+
+	if subtle.ConstantTimeCompare([]byte(session), []byte(cfg.nonceSession)) != 1 {
+		return errors.New("invalid nonce session")
+	}
+
+	if time.Now().After(expireAt) {
+		return errors.New("expired nonce")
+	}
+
+	return nil
+}
+
+// withoutNonce returns scope without NonceKey.
+func withoutNonce(scope url.Values) url.Values {
+	if _, ok := scope[NonceKey]; !ok {
+		return scope
+	}
+
+	stripped := make(url.Values, len(scope))
+	for k, v := range scope {
+		if k != NonceKey {
+			stripped[k] = v
+		}
+	}
+	return stripped
+}
+
+// nonceSweepInterval is how often MemoryNonceStore drops expired nonces.
+const nonceSweepInterval = time.Minute
+
+// MemoryNonceStore is a NonceStore which keeps nonces in memory.
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]issuedNonce
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type issuedNonce struct {
+	session  string
+	expireAt time.Time
+}
+
+// NewMemoryNonceStore returns an empty MemoryNonceStore.
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]issuedNonce), now: time.Now}
+}
+
+// Put implements NonceStore. Expired nonces are swept by Put at most once
+// per minute, so that issuing them stays cheap.
+func (m *MemoryNonceStore) Put(ctx context.Context, nonce, session string, expireAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now := m.now(); now.Sub(m.lastSweep) >= nonceSweepInterval {
+		for k, n := range m.nonces {
+			if now.After(n.expireAt) {
+				delete(m.nonces, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	m.nonces[nonce] = issuedNonce{session, expireAt}
+	return nil
+}
+
+// Take implements NonceStore.
+func (m *MemoryNonceStore) Take(ctx context.Context, nonce string) (string, time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return "", time.Time{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.nonces[nonce]
+	if !ok {
+		return "", time.Time{}, ErrNonceNotFound
+	}
+
+	delete(m.nonces, nonce)
+	return n.session, n.expireAt, nil
+}
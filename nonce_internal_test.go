@@ -0,0 +1,29 @@
+package securelogin
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryNonceStoreSweep(t *testing.T) {
+	var (
+		ctx = context.Background()
+		now = time.Now()
+		m   = NewMemoryNonceStore()
+	)
+	m.now = func() time.Time { return now }
+
+	m.Put(ctx, "a", "", now.Add(time.Second))
+	now = now.Add(2 * time.Second)
+	m.Put(ctx, "b", "", now.Add(time.Hour))
+	if len(m.nonces) != 2 {
+		t.Fatalf("Expected no sweep within a minute; got %d nonces", len(m.nonces))
+	}
+
+	now = now.Add(nonceSweepInterval)
+	m.Put(ctx, "c", "", now.Add(time.Hour))
+	if _, ok := m.nonces["a"]; ok || len(m.nonces) != 2 {
+		t.Fatalf("Expected expired nonces to be swept; got %v", m.nonces)
+	}
+}
@@ -0,0 +1,109 @@
+package securelogin_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/vladimiroff/securelogin"
+	"github.com/vladimiroff/securelogin/securelogintest"
+)
+
+var ctx = context.Background()
+
+func signWithNonce(nonce string, scope url.Values) securelogintest.Params {
+	p := securelogintest.New("user@example.com")
+	p.Scope = url.Values{securelogin.NonceKey: []string{nonce}}
+	for k, v := range scope {
+		p.Scope[k] = v
+	}
+	return p
+}
+
+func verifyWithNonce(t *testing.T, store securelogin.NonceStore, session string, token []byte, opts ...securelogin.Option) error {
+	t.Helper()
+	opts = append(opts, securelogin.WithOrigins(securelogintest.Origin), securelogin.WithNonce(store, session))
+	_, err := securelogin.Verify(token, opts...)
+	return err
+}
+
+func expectError(t *testing.T, expected string, err error) {
+	t.Helper()
+	if expected == "" {
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return
+	}
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expected error %q; got %v", expected, err)
+	}
+}
+
+func TestNonce(t *testing.T) {
+	store := securelogin.NewMemoryNonceStore()
+
+	nonce, err := securelogin.NewNonce(ctx, store, "session", time.Minute)
+	expectError(t, "", err)
+
+	token := signWithNonce(nonce, nil)
+	expectError(t, "invalid signature", verifyWithNonce(t, store, "session", token.BadSignature().Encode()))
+	expectError(t, "", verifyWithNonce(t, store, "session", token.Encode()))
+	expectError(t, "invalid nonce", verifyWithNonce(t, store, "session", token.Encode()))
+}
+
+func TestNonceErrors(t *testing.T) {
+	store := securelogin.NewMemoryNonceStore()
+
+	nonce, _ := securelogin.NewNonce(ctx, store, "session", time.Minute)
+	expectError(t, "invalid nonce session", verifyWithNonce(t, store, "other", signWithNonce(nonce, nil).Encode()))
+	expectError(t, "invalid nonce", verifyWithNonce(t, store, "session", signWithNonce(nonce, nil).Encode()))
+
+	nonce, _ = securelogin.NewNonce(ctx, store, "session", -time.Second)
+	expectError(t, "expired nonce", verifyWithNonce(t, store, "session", signWithNonce(nonce, nil).Encode()))
+
+	expectError(t, "missing nonce", verifyWithNonce(t, store, "session", securelogintest.New("user@example.com").Encode()))
+	expectError(t, "invalid nonce", verifyWithNonce(t, store, "session", signWithNonce("forged", nil).Encode()))
+}
+
+// wrappingStore wraps the errors of a MemoryNonceStore.
+type wrappingStore struct{ *securelogin.MemoryNonceStore }
+
+func (w wrappingStore) Take(ctx context.Context, nonce string) (string, time.Time, error) {
+	session, expireAt, err := w.MemoryNonceStore.Take(ctx, nonce)
+	if err != nil {
+		err = fmt.Errorf("wrapped: %w", err)
+	}
+	return session, expireAt, err
+}
+
+func TestNonceWrappedNotFound(t *testing.T) {
+	store := wrappingStore{securelogin.NewMemoryNonceStore()}
+	expectError(t, "invalid nonce", verifyWithNonce(t, store, "session", signWithNonce("forged", nil).Encode()))
+}
+
+func TestNonceWithScope(t *testing.T) {
+	var (
+		store  = securelogin.NewMemoryNonceStore()
+		scope  = url.Values{"action": []string{"transfer"}}
+		change = url.Values{"mode": []string{"change"}, "to": []string{"..."}}
+	)
+
+	nonce, _ := securelogin.NewNonce(ctx, store, "session", time.Minute)
+	token := signWithNonce(nonce, scope).Encode()
+	expectError(t, "invalid scope", verifyWithNonce(t, store, "session", token))
+	expectError(t, "", verifyWithNonce(t, store, "session", token, securelogin.WithScope(scope)))
+
+	// Requests may carry the nonce in the expected scope as well.
+	nonce, _ = securelogin.NewNonce(ctx, store, "session", time.Minute)
+	req := securelogin.NewRequest(securelogintest.Origin)
+	req.Scope = signWithNonce(nonce, scope).Scope
+	token = signWithNonce(nonce, scope).Encode()
+	expectError(t, "", verifyWithNonce(t, store, "session", token, req.Options()...))
+
+	nonce, _ = securelogin.NewNonce(ctx, store, "session", time.Minute)
+	token = signWithNonce(nonce, change).Encode()
+	expectError(t, "", verifyWithNonce(t, store, "session", token, securelogin.WithChange))
+}
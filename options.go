@@ -5,13 +5,21 @@ import "net/url"
 // Config is used for verification of a token.
 type Config struct {
 	publicKey  []byte
-	hmacSecret []byte
+	hmacSecret Secret
 	origins    map[string]struct{}
 	scope      url.Values
-	change     bool
+	mode       string
+	modes      map[string]struct{}
 	connect    bool
 	hmac       bool
 	expire     bool
+
+	lifetimes  []lifetimeRule
+	publicKeys []TrustedKey
+	secrets    []trustedSecret
+
+	nonceStore   NonceStore
+	nonceSession string
 }
 
 // Option modifies the Configuration prior verify.
@@ -22,6 +30,7 @@ func NewConfig(options ...Option) Config {
 	var cfg = Config{
 		origins: make(map[string]struct{}),
 		scope:   make(url.Values),
+		modes:   make(map[string]struct{}),
 		expire:  true,
 	}
 
@@ -41,6 +50,11 @@ func WithOrigins(origins ...string) Option {
 	}
 }
 
+func (c Config) hasOrigin(origin string) bool {
+	_, ok := c.origins[origin]
+	return ok
+}
+
 // WithScope adds given values to the scope. It replaces any existing values.
 func WithScope(scope url.Values) Option {
 	return func(c *Config) {
@@ -53,11 +67,26 @@ func WithScope(scope url.Values) Option {
 // WithPublicKey overrides PublicKey of the token.
 func WithPublicKey(pubkey []byte) Option { return func(c *Config) { c.publicKey = pubkey } }
 
-// WithSecret overrides HMACSecret of the token.
-func WithSecret(secret []byte) Option { return func(c *Config) { c.hmacSecret = secret } }
+// WithSecret overrides HMACSecret of the token. The secret is copied, so the
+// caller may wipe it afterwards.
+func WithSecret(secret []byte) Option { return WithHMACSecret(NewSecret(secret)) }
+
+// WithHMACSecret overrides HMACSecret of the token with s, which the caller
+// keeps control of and may wipe once the option is no longer used.
+func WithHMACSecret(s Secret) Option { return func(c *Config) { c.hmacSecret = s } }
 
 // WithChange enablrd "change" mode verification.
-func WithChange(c *Config) { c.change = true }
+func WithChange(c *Config) { c.mode = "change" }
+
+// WithModes accepts tokens in any of given modes, in addition to those with
+// scope matching WithScope. Modes must be registered with RegisterMode.
+func WithModes(modes ...string) Option {
+	return func(c *Config) {
+		for _, mode := range modes {
+			c.modes[mode] = struct{}{}
+		}
+	}
+}
 
 // WithConnect enables Connect request (OAuth replacement).
 func WithConnect(c *Config) { c.connect = true }
@@ -0,0 +1,89 @@
+package securelogin
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Payload is the part of a token covered by its signatures.
+type Payload struct {
+	// Provider as in Token.Provider.
+	Provider string
+
+	// Client as in Token.Client.
+	Client string
+
+	// Scope as in Token.Scope.
+	Scope url.Values
+
+	// ExpireAt as in Token.ExpireAt. It's encoded with a precision of a
+	// second.
+	ExpireAt time.Time
+}
+
+// MarshalPayload returns the bytes p is signed as: provider, client, URL
+// encoded scope and expiration time as unix seconds, joined by commas with
+// commas in each of them escaped as "%2C".
+//
+// Version1 tokens are signed over exactly these bytes. Later versions prefix
+// them with their version marker.
+func MarshalPayload(p Payload) []byte {
+	return []byte(escapeJoin([]string{
+		p.Provider,
+		p.Client,
+		p.Scope.Encode(),
+		strconv.FormatInt(p.ExpireAt.Unix(), 10),
+	}))
+}
+
+// ParsePayload parses the signed bytes of a token, as produced by
+// MarshalPayload, and fails with the same errors Unmarshal does for the
+// payload of a token.
+func ParsePayload(data []byte) (Payload, error) {
+	return parsePayload(string(data))
+}
+
+func parsePayload(s string) (Payload, error) {
+	var p Payload
+
+	payload, err := unescapeSplit(s, 4)
+	if err != nil {
+		return p, wrap("payload", err)
+	}
+
+	p.Provider = payload[0]
+	p.Client = payload[1]
+	p.Scope, err = url.ParseQuery(payload[2])
+	if err != nil {
+		return p, wrap("payload", "parsing scope failed")
+	}
+
+	expire, err := strconv.ParseInt(payload[3], 10, 64)
+	if err != nil {
+		return p, wrap("payload", "invalid expire time")
+	}
+	p.ExpireAt = time.Unix(expire, 0)
+
+	return p, nil
+}
+
+// Payload returns the signed part of t.
+func (t Token) Payload() Payload {
+	return Payload{
+		Provider: t.Provider,
+		Client:   t.Client,
+		Scope:    t.Scope,
+		ExpireAt: t.ExpireAt,
+	}
+}
+
+// SetPayload sets the fields of t from p along with the signed bytes Marshal
+// and Verify use, so that a token can be built and signed from scratch.
+func (t *Token) SetPayload(p Payload) {
+	t.Provider = p.Provider
+	t.Client = p.Client
+	t.Scope = p.Scope
+	t.ExpireAt = p.ExpireAt
+	t.rawPayload = MarshalPayload(p)
+}
@@ -0,0 +1,95 @@
+package securelogin
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPayloadRoundTrip(t *testing.T) {
+	var cases = []Payload{
+		{"https://cobased.com", "https://cobased.com", url.Values{}, time.Unix(1498731060, 0)},
+		{"https://cobased.com", "https://app.cobased.com", url.Values{"mode": {"change"}, "to": {"a+b/c="}}, time.Unix(0, 0)},
+		{"https://cobased.com", "https://cobased.com", url.Values{"list": {"a,b"}}, time.Unix(-1, 0)},
+	}
+
+	for i, p := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			data := MarshalPayload(p)
+
+			got, err := ParsePayload(data)
+			fatal(t, err)
+			if !reflect.DeepEqual(got, p) {
+				t.Fatalf("Expected %+v; got %+v", p, got)
+			}
+		})
+	}
+}
+
+func TestPayloadMatchesToken(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	if got := string(MarshalPayload(tok.Payload())); got != string(tok.rawPayload) {
+		fail(t, "payload", string(tok.rawPayload), got)
+	}
+
+	p, err := ParsePayload(tok.SignedMessage())
+	fatal(t, err)
+	if !reflect.DeepEqual(p, tok.Payload()) {
+		t.Fatalf("Expected %+v; got %+v", tok.Payload(), p)
+	}
+}
+
+func TestSignedMessageIsCopy(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	m := tok.SignedMessage()
+	m[0] ^= 0xFF
+	_ = append(m[:len(m)-1], 'x')
+
+	fatal(t, tok.Verify(o, WithoutExpire))
+	if got := string(MarshalWithSecret(tok)); got != token {
+		t.Fatalf("Expected:\t%s\nGot:\t\t\t%s", token, got)
+	}
+}
+
+func TestSetPayload(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	var built Token
+	built.SetPayload(tok.Payload())
+	built.Signature, built.HMACSignature = tok.Signature, tok.HMACSignature
+	built.PublicKey, built.HMACSecret = tok.PublicKey, tok.HMACSecret
+	built.Email = tok.Email
+
+	if got := string(MarshalWithSecret(built)); got != token {
+		t.Fatalf("Expected:\t%s\nGot:\t\t\t%s", token, got)
+	}
+	fatal(t, built.Verify(o, WithHMAC, WithoutExpire))
+}
+
+func TestParsePayloadErrors(t *testing.T) {
+	var cases = []struct {
+		data string
+		err  string
+	}{
+		{"a,b,", "expected 4 elements, got 3"},
+		{"a,b,%zz,1", "parsing scope failed"},
+		{"a,b,,never", "invalid expire time"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			_, err := ParsePayload([]byte(c.data))
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("Expected error %q; got %v", c.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,385 @@
+// Package rendezvous implements cross-device login on top of
+// securelogin.Verify.
+//
+// A browser creates a pending session and shows its payload as a QR code.
+// The SecureLogin app on the phone scans it and submits a token to the
+// callback URL embedded in the payload. Meanwhile, the browser long-polls
+// the session until it's completed.
+//
+// A session is completed only by a token signed by the key stored for its
+// user, see Server.PublicKey, which carries the nonce requested by that
+// session, so a token can't complete any other.
+//
+// The session id is part of the payload, so anyone who sees the QR code
+// knows it. Waiting on the session therefore needs a separate poll secret,
+// which is returned only to the browser that created it.
+//
+// Server exposes the following endpoints, relative to where it's mounted:
+//
+//	POST /create       creates a session
+//	POST /submit/{id}  submits an sltoken for the session
+//	GET  /poll/{id}    waits for the session to be completed; requires
+//	                   the poll secret as "Authorization: Bearer <secret>"
+package rendezvous
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vladimiroff/securelogin"
+)
+
+const (
+	// DefaultTTL is how long sessions wait for a token by default.
+	DefaultTTL = 5 * time.Minute
+
+	// DefaultPollTimeout is how long a poll request waits by default.
+	DefaultPollTimeout = 30 * time.Second
+)
+
+// pollInterval is how often Wait checks the store, in case the session was
+// completed through another Server sharing it.
+var pollInterval = time.Second
+
+// Server creates, completes and waits on sessions.
+type Server struct {
+	// Store keeps the sessions.
+	Store Store
+
+	// Request is the template for requests of new sessions.
+	Request securelogin.Request
+
+	// CallbackURL is the absolute URL of the submit endpoint, without the
+	// session id.
+	CallbackURL string
+
+	// PublicKey returns the key stored for the user of email, as found in
+	// submitted tokens, or an error if there is none. It's required, as
+	// otherwise a token signed by any key would complete sessions.
+	PublicKey func(ctx context.Context, email string) ([]byte, error)
+
+	// Nonces keeps the nonces binding tokens to sessions. Servers sharing
+	// Store must share it as well. NewServer sets a MemoryNonceStore.
+	Nonces securelogin.NonceStore
+
+	// Options to verify submitted tokens with.
+	Options []securelogin.Option
+
+	// TTL of new sessions. Zero means DefaultTTL.
+	TTL time.Duration
+
+	// PollTimeout is the longest a poll request waits. Zero means
+	// DefaultPollTimeout.
+	PollTimeout time.Duration
+
+	// Login is called by the poll endpoint once the session is completed,
+	// e.g. to start a session in the browser, with the token verified
+	// against the key returned by PublicKey. By default the status and the
+	// email are written as JSON.
+	Login func(w http.ResponseWriter, r *http.Request, t securelogin.Token)
+
+	mu      sync.Mutex
+	waiters map[string]*waiter
+}
+
+type waiter struct {
+	done chan struct{}
+	refs int
+}
+
+// NewServer returns a Server, which verifies submitted tokens against the
+// keys returned by publicKey with opts.
+func NewServer(store Store, req securelogin.Request, callbackURL string, publicKey func(ctx context.Context, email string) ([]byte, error), opts ...securelogin.Option) *Server {
+	return &Server{
+		Store:       store,
+		Request:     req,
+		CallbackURL: callbackURL,
+		PublicKey:   publicKey,
+		Nonces:      securelogin.NewMemoryNonceStore(),
+		Options:     opts,
+	}
+}
+
+// Create starts a new pending session.
+func (s *Server) Create(ctx context.Context) (Session, error) {
+	id, err := newID()
+	if err != nil {
+		return Session{}, err
+	}
+	secret, err := newID()
+	if err != nil {
+		return Session{}, err
+	}
+
+	ttl := s.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	if s.Nonces == nil {
+		return Session{}, errors.New("rendezvous: no NonceStore")
+	}
+	nonce, err := securelogin.NewNonce(ctx, s.Nonces, id, ttl)
+	if err != nil {
+		return Session{}, err
+	}
+
+	req := s.Request
+	req.Callback = strings.TrimSuffix(s.CallbackURL, "/") + "/" + id
+	req.Scope = make(url.Values, len(s.Request.Scope)+1)
+	for k, v := range s.Request.Scope {
+		req.Scope[k] = v
+	}
+	req.Scope.Set(securelogin.NonceKey, nonce)
+
+	session := Session{
+		ID:         id,
+		Request:    req,
+		ExpireAt:   time.Now().Add(ttl),
+		PollSecret: secret,
+	}
+	return session, s.Store.Create(ctx, session)
+}
+
+// Payload returns the text to be encoded in a QR code for session.
+func Payload(session Session) string {
+	return session.Request.AppURL()
+}
+
+// Submit verifies token against the request of the session and the key
+// stored for its user, and completes the session.
+func (s *Server) Submit(ctx context.Context, id string, token []byte) (securelogin.Token, error) {
+	session, err := s.Store.Get(ctx, id)
+	if err != nil {
+		return securelogin.Token{}, err
+	}
+	if !session.Pending() {
+		return securelogin.Token{}, ErrCompleted
+	}
+	if s.PublicKey == nil || s.Nonces == nil {
+		return securelogin.Token{}, errors.New("rendezvous: PublicKey and Nonces are required")
+	}
+
+	t, err := securelogin.Unmarshal(token)
+	if err != nil {
+		return t, err
+	}
+
+	key, err := s.PublicKey(ctx, t.Email)
+	if err != nil {
+		return t, err
+	}
+
+	opts := append(session.Request.Options(), s.Options...)
+	opts = append(opts, securelogin.WithPublicKey(key), securelogin.WithNonce(s.Nonces, id))
+	if err := t.VerifyContext(ctx, opts...); err != nil {
+		return t, err
+	}
+
+	if err := s.Store.Complete(ctx, id, t); err != nil {
+		return t, err
+	}
+
+	s.notify(id)
+	return t, nil
+}
+
+// Wait blocks until the session is completed or ctx is done, in which case
+// the still pending session is returned along with ctx.Err(). It fails with
+// ErrNotFound unless secret is the PollSecret of the session.
+func (s *Server) Wait(ctx context.Context, id, secret string) (Session, error) {
+	w := s.subscribe(id)
+	defer s.unsubscribe(id, w)
+
+	session, err := s.Store.Get(ctx, id)
+	if err != nil {
+		return session, err
+	}
+	if session.PollSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(session.PollSecret)) != 1 {
+		return Session{}, ErrNotFound
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if !session.Pending() {
+			return session, nil
+		}
+
+		select {
+		case <-w.done:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return session, ctx.Err()
+		}
+
+		if session, err = s.Store.Get(ctx, id); err != nil {
+			return session, err
+		}
+	}
+}
+
+func (s *Server) subscribe(id string) *waiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.waiters == nil {
+		s.waiters = make(map[string]*waiter)
+	}
+
+	w, ok := s.waiters[id]
+	if !ok {
+		w = &waiter{done: make(chan struct{})}
+		s.waiters[id] = w
+	}
+	w.refs++
+	return w
+}
+
+func (s *Server) unsubscribe(id string, w *waiter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.refs--
+	if w.refs == 0 && s.waiters[id] == w {
+		delete(s.waiters, id)
+	}
+}
+
+func (s *Server) notify(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if w, ok := s.waiters[id]; ok {
+		close(w.done)
+		delete(s.waiters, id)
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		path   = strings.TrimPrefix(r.URL.Path, "/")
+		action = path
+		id     string
+	)
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		action, id = path[:i], path[i+1:]
+	}
+
+	switch {
+	case action == "create" && id == "" && r.Method == http.MethodPost:
+		s.serveCreate(w, r)
+	case action == "submit" && id != "" && r.Method == http.MethodPost:
+		s.serveSubmit(w, r, id)
+	case action == "poll" && id != "" && r.Method == http.MethodGet:
+		s.servePoll(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveCreate(w http.ResponseWriter, r *http.Request) {
+	session, err := s.Create(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":          session.ID,
+		"poll_secret": session.PollSecret,
+		"payload":     Payload(session),
+		"expire_at":   session.ExpireAt.Unix(),
+	})
+}
+
+func (s *Server) serveSubmit(w http.ResponseWriter, r *http.Request, id string) {
+	token := []byte(r.PostFormValue("sltoken"))
+	if len(token) == 0 {
+		var err error
+		if token, err = io.ReadAll(io.LimitReader(r.Body, 1<<16)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch _, err := s.Submit(r.Context(), id, token); {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrCompleted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	}
+}
+
+func (s *Server) servePoll(w http.ResponseWriter, r *http.Request, id string) {
+	timeout := s.PollTimeout
+	if timeout == 0 {
+		timeout = DefaultPollTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	secret := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	session, err := s.Wait(ctx, id, secret)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		writeJSON(w, http.StatusOK, map[string]string{"status": "pending"})
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// A completed session logs in exactly one browser.
+	switch err := s.Store.Delete(r.Context(), id); {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if s.Login != nil {
+		s.Login(w, r, *session.Token)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "done",
+		"email":  session.Token.Email,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func newID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
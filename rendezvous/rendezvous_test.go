@@ -0,0 +1,250 @@
+package rendezvous
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vladimiroff/securelogin"
+	"github.com/vladimiroff/securelogin/securelogintest"
+)
+
+const email = "user@example.com"
+
+// publicKey knows only email, whose key is securelogintest.NewKey(email).
+func publicKey(ctx context.Context, e string) ([]byte, error) {
+	if e != email {
+		return nil, errors.New("unknown user")
+	}
+	return securelogintest.NewKey(email).PublicKey, nil
+}
+
+// forSession returns a copy of p responding to req.
+func forSession(p securelogintest.Params, req securelogin.Request) securelogintest.Params {
+	p.Scope = req.Scope
+	return p
+}
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	s := NewServer(
+		NewMemoryStore(),
+		securelogin.NewRequest(securelogintest.Origin),
+		"",
+		publicKey,
+		securelogin.WithOrigins(securelogintest.Origin),
+	)
+	s.PollTimeout = 50 * time.Millisecond
+
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	s.CallbackURL = ts.URL + "/submit"
+	return s, ts
+}
+
+func create(t *testing.T, ts *httptest.Server) (id, secret string, req securelogin.Request) {
+	resp, err := http.Post(ts.URL+"/create", "", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status %d; got %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	var body struct {
+		ID         string `json:"id"`
+		PollSecret string `json:"poll_secret"`
+		Payload    string `json:"payload"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if body.PollSecret == "" || strings.Contains(body.Payload, body.PollSecret) {
+		t.Fatalf("Expected poll secret outside of the payload; got %q in %q", body.PollSecret, body.Payload)
+	}
+
+	req, err = securelogin.ParseRequest(body.Payload)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return body.ID, body.PollSecret, req
+}
+
+func submit(t *testing.T, callback string, token []byte) int {
+	resp, err := http.PostForm(callback, url.Values{"sltoken": []string{string(token)}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func pollRequest(ts *httptest.Server, id, secret string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/poll/"+id, nil)
+	if secret != "" {
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+	return req
+}
+
+func poll(t *testing.T, ts *httptest.Server, id, secret string) (int, map[string]string) {
+	resp, err := http.DefaultClient.Do(pollRequest(ts, id, secret))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var body map[string]string
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func TestLogin(t *testing.T) {
+	s, ts := newTestServer(t)
+	id, secret, req := create(t, ts)
+
+	if !strings.HasSuffix(req.Callback, "/submit/"+id) {
+		t.Fatalf("Expected callback to submit %s; got %s", id, req.Callback)
+	}
+
+	if code, body := poll(t, ts, id, secret); code != http.StatusOK || body["status"] != "pending" {
+		t.Fatalf("Expected pending; got %d %v", code, body)
+	}
+
+	s.PollTimeout = time.Minute
+	done := make(chan map[string]string)
+	go func() {
+		var body map[string]string
+		if resp, err := http.DefaultClient.Do(pollRequest(ts, id, secret)); err == nil {
+			json.NewDecoder(resp.Body).Decode(&body)
+			resp.Body.Close()
+		}
+		done <- body
+	}()
+
+	token := forSession(securelogintest.New(email), req).Encode()
+	if code := submit(t, req.Callback, token); code != http.StatusNoContent {
+		t.Fatalf("Expected status %d; got %d", http.StatusNoContent, code)
+	}
+
+	if body := <-done; body["status"] != "done" || body["email"] != email {
+		t.Fatalf("Expected done; got %v", body)
+	}
+
+	if code, _ := poll(t, ts, id, secret); code != http.StatusNotFound {
+		t.Fatalf("Expected consumed session to be gone; got %d", code)
+	}
+
+	if code := submit(t, req.Callback, token); code != http.StatusNotFound {
+		t.Fatalf("Expected status %d; got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestSubmitErrors(t *testing.T) {
+	s, ts := newTestServer(t)
+
+	_, _, req := create(t, ts)
+	user := forSession(securelogintest.New(email), req)
+	attacker := user
+	attacker.Key = securelogintest.NewKey("attacker")
+
+	var cases = []struct {
+		token []byte
+		code  int
+	}{
+		{[]byte("garbage"), http.StatusForbidden},
+		{user.Expired().Encode(), http.StatusForbidden},
+		{user.BadSignature().Encode(), http.StatusForbidden},
+		{user.WrongClient().Encode(), http.StatusForbidden},
+		{user.Change(securelogintest.NewKey("new")).Encode(), http.StatusForbidden},
+		{securelogintest.New(email).Encode(), http.StatusForbidden},
+		{attacker.Encode(), http.StatusForbidden},
+		{forSession(securelogintest.New("other@example.com"), req).Encode(), http.StatusForbidden},
+		{user.Encode(), http.StatusNoContent},
+		{user.Encode(), http.StatusConflict},
+	}
+
+	for _, c := range cases {
+		if code := submit(t, req.Callback, c.token); code != c.code {
+			t.Errorf("Expected status %d; got %d for %s", c.code, code, c.token)
+		}
+	}
+
+	if code := submit(t, s.CallbackURL+"/unknown", user.Encode()); code != http.StatusNotFound {
+		t.Fatalf("Expected status %d; got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestTokenBoundToSession(t *testing.T) {
+	_, ts := newTestServer(t)
+	_, _, first := create(t, ts)
+	secondID, secondSecret, second := create(t, ts)
+
+	token := forSession(securelogintest.New(email), first).Encode()
+	if code := submit(t, first.Callback, token); code != http.StatusNoContent {
+		t.Fatalf("Expected status %d; got %d", http.StatusNoContent, code)
+	}
+	if code := submit(t, second.Callback, token); code != http.StatusForbidden {
+		t.Fatalf("Expected status %d; got %d", http.StatusForbidden, code)
+	}
+
+	// Nor does a token for one pending session complete another.
+	_, _, third := create(t, ts)
+	token = forSession(securelogintest.New(email), third).Encode()
+	if code := submit(t, second.Callback, token); code != http.StatusForbidden {
+		t.Fatalf("Expected status %d; got %d", http.StatusForbidden, code)
+	}
+	if code, body := poll(t, ts, secondID, secondSecret); code != http.StatusOK || body["status"] != "pending" {
+		t.Fatalf("Expected pending; got %d %v", code, body)
+	}
+}
+
+func TestPollSecret(t *testing.T) {
+	_, ts := newTestServer(t)
+	id, secret, req := create(t, ts)
+
+	token := forSession(securelogintest.New(email), req).Encode()
+	if code := submit(t, req.Callback, token); code != http.StatusNoContent {
+		t.Fatalf("Expected status %d; got %d", http.StatusNoContent, code)
+	}
+
+	for _, s := range []string{"", id, secret[1:], secret + "x"} {
+		if code, body := poll(t, ts, id, s); code != http.StatusNotFound {
+			t.Errorf("Expected status %d for secret %q; got %d %v", http.StatusNotFound, s, code, body)
+		}
+	}
+
+	if code, body := poll(t, ts, id, secret); code != http.StatusOK || body["status"] != "done" {
+		t.Fatalf("Expected done; got %d %v", code, body)
+	}
+}
+
+func TestRouting(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	for _, c := range []struct{ method, path string }{
+		{http.MethodGet, "/create"},
+		{http.MethodGet, "/submit/id"},
+		{http.MethodPost, "/poll/id"},
+		{http.MethodGet, "/poll/"},
+		{http.MethodGet, "/"},
+	} {
+		req, _ := http.NewRequest(c.method, ts.URL+c.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s %s: expected status 404; got %d", c.method, c.path, resp.StatusCode)
+		}
+	}
+}
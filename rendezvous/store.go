@@ -0,0 +1,133 @@
+package rendezvous
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/vladimiroff/securelogin"
+)
+
+var (
+	// ErrNotFound is returned for unknown or expired sessions.
+	ErrNotFound = errors.New("rendezvous: session not found")
+
+	// ErrCompleted is returned when completing a session twice.
+	ErrCompleted = errors.New("rendezvous: session already completed")
+)
+
+// Session is a login waiting for a token from another device.
+type Session struct {
+	ID       string
+	Request  securelogin.Request
+	ExpireAt time.Time
+
+	// PollSecret authorizes waiting on the session. Unlike ID, it is not
+	// part of the payload, so only the browser which created the session
+	// knows it.
+	PollSecret string
+
+	// Token is set once the session is completed.
+	Token *securelogin.Token
+}
+
+// Pending reports whether s still waits for a token.
+func (s Session) Pending() bool {
+	return s.Token == nil
+}
+
+// Store keeps sessions until they expire. Implementations must be safe for
+// concurrent use and must not return sessions past their ExpireAt.
+type Store interface {
+	// Create stores a new session.
+	Create(ctx context.Context, s Session) error
+
+	// Get returns the session with given id.
+	Get(ctx context.Context, id string) (Session, error)
+
+	// Complete attaches t to a pending session.
+	Complete(ctx context.Context, id string, t securelogin.Token) error
+
+	// Delete removes the session with given id. It fails with ErrNotFound
+	// unless the session exists, so only one of concurrent callers succeeds.
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore is a Store which keeps sessions in memory.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+	now      func() time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]Session), now: time.Now}
+}
+
+// Create implements Store.
+func (m *MemoryStore) Create(ctx context.Context, s Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for id, s := range m.sessions {
+		if !now.Before(s.ExpireAt) {
+			delete(m.sessions, id)
+		}
+	}
+
+	m.sessions[s.ID] = s
+	return nil
+}
+
+// Get implements Store.
+func (m *MemoryStore) Get(ctx context.Context, id string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.get(id)
+}
+
+// Complete implements Store.
+func (m *MemoryStore) Complete(ctx context.Context, id string, t securelogin.Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	if !s.Pending() {
+		return ErrCompleted
+	}
+
+	s.Token = &t
+	m.sessions[id] = s
+	return nil
+}
+
+// Delete implements Store.
+func (m *MemoryStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.get(id); err != nil {
+		return err
+	}
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemoryStore) get(id string) (Session, error) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return s, ErrNotFound
+	}
+	if !m.now().Before(s.ExpireAt) {
+		delete(m.sessions, id)
+		return Session{}, ErrNotFound
+	}
+	return s, nil
+}
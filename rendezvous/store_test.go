@@ -0,0 +1,75 @@
+package rendezvous
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vladimiroff/securelogin"
+)
+
+func TestMemoryStore(t *testing.T) {
+	var (
+		ctx = context.Background()
+		now = time.Now()
+		m   = NewMemoryStore()
+	)
+	m.now = func() time.Time { return now }
+
+	if err := m.Create(ctx, Session{ID: "a", ExpireAt: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := m.Get(ctx, "b"); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound; got %v", err)
+	}
+
+	s, err := m.Get(ctx, "a")
+	if err != nil || !s.Pending() {
+		t.Fatalf("Expected pending session; got %#v, %v", s, err)
+	}
+
+	if err := m.Complete(ctx, "a", securelogin.Token{Email: "user@example.com"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := m.Complete(ctx, "a", securelogin.Token{}); err != ErrCompleted {
+		t.Fatalf("Expected ErrCompleted; got %v", err)
+	}
+
+	s, err = m.Get(ctx, "a")
+	if err != nil || s.Pending() || s.Token.Email != "user@example.com" {
+		t.Fatalf("Expected completed session; got %#v, %v", s, err)
+	}
+
+	if err := m.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := m.Delete(ctx, "a"); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound; got %v", err)
+	}
+}
+
+func TestMemoryStoreExpire(t *testing.T) {
+	var (
+		ctx = context.Background()
+		now = time.Now()
+		m   = NewMemoryStore()
+	)
+	m.now = func() time.Time { return now }
+
+	m.Create(ctx, Session{ID: "a", ExpireAt: now.Add(time.Minute)})
+	m.Create(ctx, Session{ID: "b", ExpireAt: now.Add(time.Hour)})
+
+	now = now.Add(time.Minute)
+	if _, err := m.Get(ctx, "a"); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound; got %v", err)
+	}
+	if err := m.Complete(ctx, "a", securelogin.Token{}); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound; got %v", err)
+	}
+
+	m.Create(ctx, Session{ID: "c", ExpireAt: now.Add(time.Hour)})
+	if len(m.sessions) != 2 {
+		t.Fatalf("Expected expired sessions to be swept; got %d", len(m.sessions))
+	}
+}
@@ -0,0 +1,151 @@
+package securelogin
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// AppURL invokes the SecureLogin app installed on the device.
+	AppURL = "securelogin://"
+
+	// WebURL is the web fallback for devices without the app.
+	WebURL = "https://securelogin.pw/s"
+)
+
+// Request describes a login request passed to the SecureLogin app, which
+// responds with a token for the same provider, client and scope.
+type Request struct {
+	// Provider is the origin of the app the user logs into.
+	Provider string
+
+	// Client is the origin requesting the token. Empty means Provider.
+	Client string
+
+	// Scope requested from the user. Empty for sign-(in|up).
+	Scope url.Values
+
+	// Callback is the URL the app sends the token to. Empty means the
+	// token is handed back to the opening page.
+	Callback string
+}
+
+// NewRequest returns a sign-in Request for provider.
+func NewRequest(provider string) Request {
+	return Request{Provider: provider, Client: provider}
+}
+
+// AppURL returns the URL which opens the SecureLogin app with r.
+func (r Request) AppURL() string {
+	return AppURL + "#" + r.encode()
+}
+
+// WebURL returns the web fallback URL for r.
+func (r Request) WebURL() string {
+	return WebURL + "#" + r.encode()
+}
+
+func (r Request) encode() string {
+	v := url.Values{"provider": []string{r.Provider}}
+	if r.Client != "" && r.Client != r.Provider {
+		v.Set("client", r.Client)
+	}
+	if len(r.Scope) > 0 {
+		v.Set("scope", r.Scope.Encode())
+	}
+	if r.Callback != "" {
+		v.Set("callback", r.Callback)
+	}
+	return v.Encode()
+}
+
+// ParseRequest parses either an app or a web fallback URL.
+func ParseRequest(s string) (Request, error) {
+	var r Request
+
+	switch {
+	case strings.HasPrefix(s, AppURL):
+		s = strings.TrimPrefix(s, AppURL)
+	case strings.HasPrefix(s, WebURL):
+		s = strings.TrimPrefix(s, WebURL)
+	default:
+		return r, errors.New("request: unknown URL")
+	}
+
+	// Parameters are in the fragment, but accept a query as well.
+	if !strings.HasPrefix(s, "#") && !strings.HasPrefix(s, "?") {
+		return r, errors.New("request: missing parameters")
+	}
+
+	v, err := url.ParseQuery(s[1:])
+	if err != nil {
+		return r, fmt.Errorf("request: %s", err)
+	}
+
+	r.Provider = v.Get("provider")
+	if r.Provider == "" {
+		return r, errors.New("request: missing provider")
+	}
+
+	r.Client = v.Get("client")
+	if r.Client == "" {
+		r.Client = r.Provider
+	}
+
+	if scope := v.Get("scope"); scope != "" {
+		if r.Scope, err = url.ParseQuery(scope); err != nil {
+			return r, fmt.Errorf("request: invalid scope: %s", err)
+		}
+	}
+
+	r.Callback = v.Get("callback")
+	return r, nil
+}
+
+// Validate checks that a token issued for r would be accepted by Verify with
+// the same options, and that the callback belongs to one of the origins.
+func (r Request) Validate(opts ...Option) error {
+	var cfg = NewConfig(append(r.Options(), opts...)...)
+
+	if _, ok := cfg.origins[r.Provider]; !ok {
+		return ErrInvalidProvider
+	}
+
+	if !cfg.connect {
+		if _, ok := cfg.origins[r.client()]; !ok {
+			return ErrInvalidClient
+		}
+	}
+
+	if r.Callback != "" {
+		u, err := url.Parse(r.Callback)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.New("invalid callback")
+		}
+		if _, ok := cfg.origins[u.Scheme+"://"+u.Host]; !ok {
+			return errors.New("invalid callback origin")
+		}
+	}
+
+	_, err := verifyScope(cfg, r.Scope)
+	return err
+}
+
+// Options returns the options a token responding to r is expected to
+// verify with, in addition to the allowed origins.
+func (r Request) Options() []Option {
+	opts := []Option{WithScope(r.Scope)}
+	if r.client() != r.Provider {
+		opts = append(opts, WithConnect)
+	}
+	return opts
+}
+
+func (r Request) client() string {
+	if r.Client == "" {
+		return r.Provider
+	}
+	return r.Client
+}
@@ -0,0 +1,103 @@
+package securelogin
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestRequestRoundTrip(t *testing.T) {
+	var cases = []Request{
+		NewRequest(domain),
+		{Provider: domain, Client: "https://app.example.com"},
+		{Provider: domain, Client: domain, Scope: changeScope},
+		{Provider: domain, Client: domain, Scope: accessAllScope, Callback: domain + "/login?next=/home"},
+	}
+
+	for i, r := range cases {
+		for _, s := range []string{r.AppURL(), r.WebURL()} {
+			t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+				parsed, err := ParseRequest(s)
+				fatal(t, err)
+				if !reflect.DeepEqual(r, parsed) {
+					t.Fatalf("Expected %#v; got %#v from %s", r, parsed, s)
+				}
+			})
+		}
+	}
+}
+
+func TestRequestURL(t *testing.T) {
+	r := NewRequest(domain)
+	r.Callback = domain + "/login"
+
+	expected := "securelogin://#callback=https%3A%2F%2Fcobased.com%2Flogin&provider=https%3A%2F%2Fcobased.com"
+	if r.AppURL() != expected {
+		t.Fatalf("Expected %s; got %s", expected, r.AppURL())
+	}
+
+	expected = "https://securelogin.pw/s#callback=https%3A%2F%2Fcobased.com%2Flogin&provider=https%3A%2F%2Fcobased.com"
+	if r.WebURL() != expected {
+		t.Fatalf("Expected %s; got %s", expected, r.WebURL())
+	}
+}
+
+func TestParseRequestErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"https://cobased.com/#provider=https://cobased.com",
+		"securelogin://",
+		"securelogin://#client=https://cobased.com",
+		"securelogin://#provider=https://cobased.com&scope=%",
+		"securelogin://#provider=%zz",
+	} {
+		if r, err := ParseRequest(s); err == nil {
+			t.Errorf("ParseRequest(%q) = %#v; expected error", s, r)
+		}
+	}
+}
+
+func TestRequestValidate(t *testing.T) {
+	var cases = []struct {
+		req Request
+		opt []Option
+		err string
+	}{
+		{NewRequest(domain), []Option{o}, ""},
+		{Request{Provider: domain}, []Option{o}, ""},
+		{NewRequest("evilcorp.com"), []Option{o}, "invalid provider"},
+		{Request{Provider: domain, Client: "evilcorp.com"}, []Option{o}, ""},
+		{Request{Provider: domain, Callback: domain + "/callback"}, []Option{o}, ""},
+		{Request{Provider: domain, Callback: "https://evilcorp.com/callback"}, []Option{o}, "invalid callback origin"},
+		{Request{Provider: domain, Callback: "/callback"}, []Option{o}, "invalid callback"},
+		{Request{Provider: domain, Scope: changeScope}, []Option{o, WithChange}, ""},
+		{Request{Provider: domain, Scope: accessAllScope}, []Option{o, WithChange}, "not mode=change token"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			err := c.req.Validate(c.opt...)
+			if c.err == "" {
+				fatal(t, err)
+			} else if err == nil || err.Error() != c.err {
+				t.Fatalf("Expected error %q; got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestRequestOptionsMatchVerify(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	r := NewRequest(domain)
+	opts := append(r.Options(), o, WithoutExpire)
+	fatal(t, tok.Verify(opts...))
+
+	r.Scope = url.Values{"access": []string{"all"}}
+	opts = append(r.Options(), o, WithoutExpire)
+	if err := tok.Verify(opts...); err == nil || err.Error() != "invalid scope" {
+		t.Fatalf("Expected invalid scope; got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package securelogin
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// Secret holds key material, such as a HMAC secret.
+//
+// Copies of a Secret share the same bytes, so passing it, or a Token which
+// holds it, by value doesn't copy the material, and Wipe clears it for every
+// copy. A Secret is always formatted, logged and encoded as "[redacted]".
+// The zero Secret is empty.
+type Secret struct {
+	buf *secretBuffer
+}
+
+type secretBuffer struct {
+	b []byte
+}
+
+// NewSecret returns a Secret holding a copy of b. The caller may wipe b
+// afterwards.
+func NewSecret(b []byte) Secret {
+	return newSecret(append([]byte(nil), b...))
+}
+
+// newSecret returns a Secret which takes ownership of b.
+func newSecret(b []byte) Secret {
+	return Secret{&secretBuffer{b}}
+}
+
+// Bytes returns the material held by s, without copying it. It must not be
+// modified nor retained, as it's cleared by Wipe.
+func (s Secret) Bytes() []byte {
+	if s.buf == nil {
+		return nil
+	}
+	return s.buf.b
+}
+
+// Len returns the length of the material held by s.
+func (s Secret) Len() int {
+	return len(s.Bytes())
+}
+
+// Equal reports whether s and other hold the same material, in constant
+// time.
+func (s Secret) Equal(other Secret) bool {
+	return subtle.ConstantTimeCompare(s.Bytes(), other.Bytes()) == 1
+}
+
+// Wipe overwrites the material held by s with zeros and empties s and all of
+// its copies.
+func (s Secret) Wipe() {
+	if s.buf == nil {
+		return
+	}
+	for i := range s.buf.b {
+		s.buf.b[i] = 0
+	}
+	s.buf.b = s.buf.b[:0]
+}
+
+// String returns "[redacted]".
+func (s Secret) String() string { return redacted }
+
+// GoString returns "[redacted]".
+func (s Secret) GoString() string { return redacted }
+
+// Format implements fmt.Formatter, so that s is redacted for every verb.
+func (s Secret) Format(f fmt.State, verb rune) { fmt.Fprint(f, redacted) }
+
+// LogValue implements slog.LogValuer.
+func (s Secret) LogValue() slog.Value { return slog.StringValue(redacted) }
+
+// MarshalText returns "[redacted]", so that s is never encoded, e.g. in
+// JSON.
+func (s Secret) MarshalText() ([]byte, error) { return []byte(redacted), nil }
+
+// ErrSecretNotEncoded is returned when decoding a Secret from text. Secrets
+// are encoded as "[redacted]", so the material can't be recovered.
+var ErrSecretNotEncoded = errors.New("secret is not encoded, use MarshalWithSecret")
+
+// UnmarshalText always fails with ErrSecretNotEncoded, so that decoding a
+// Token encoded with MarshalText, e.g. from JSON, doesn't silently lose the
+// secret.
+func (s *Secret) UnmarshalText([]byte) error { return ErrSecretNotEncoded }
@@ -0,0 +1,109 @@
+package securelogin
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSecretWipe(t *testing.T) {
+	b := []byte("secret")
+	s := NewSecret(b)
+	b[0] = 'X'
+	if string(s.Bytes()) != "secret" {
+		t.Fatalf("Expected NewSecret to copy; got %q", s.Bytes())
+	}
+
+	material := s.Bytes()
+	shared := s
+	s.Wipe()
+
+	if shared.Len() != 0 {
+		t.Fatalf("Expected copies to be wiped; got %d bytes", shared.Len())
+	}
+	for _, c := range material {
+		if c != 0 {
+			t.Fatalf("Expected material to be zeroed; got %q", material)
+		}
+	}
+
+	var zero Secret
+	zero.Wipe()
+	if zero.Len() != 0 || zero.Bytes() != nil {
+		t.Fatalf("Expected zero secret to be empty")
+	}
+}
+
+func TestSecretRedacted(t *testing.T) {
+	s := NewSecret([]byte("secret"))
+
+	data, err := json.Marshal(struct{ S Secret }{s})
+	fatal(t, err)
+
+	for i, out := range []string{
+		s.String(),
+		fmt.Sprintf("%v %+v %#v %s %q %x %d", s, s, s, s, s, s, s),
+		string(data),
+	} {
+		if strings.Contains(out, "secret") || strings.Contains(out, fmt.Sprintf("%x", "secret")) {
+			t.Fatalf("%d: Expected secret to be redacted in %s", i, out)
+		}
+	}
+}
+
+func TestSecretEqual(t *testing.T) {
+	a, b := NewSecret([]byte("a")), NewSecret([]byte("a"))
+	if !a.Equal(b) || a.Equal(NewSecret([]byte("b"))) {
+		t.Fatalf("Expected secrets to be compared by material")
+	}
+}
+
+func TestTokenWipe(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	copied := tok
+	tok.Wipe()
+	if copied.HMACSecret.Len() != 0 {
+		t.Fatalf("Expected copies of the token to be wiped")
+	}
+	if err := copied.Verify(o, WithoutExpire, WithHMAC); err == nil {
+		t.Fatalf("Expected error; got nil")
+	}
+}
+
+func TestUnmarshalIntoKeepsCopies(t *testing.T) {
+	var tok Token
+	fatal(t, UnmarshalInto([]byte(token), &tok))
+
+	for _, next := range []string{v2Token(), token} {
+		copied, secret := tok, string(tok.HMACSecret.Bytes())
+		fatal(t, UnmarshalInto([]byte(next), &tok))
+
+		if got := string(copied.HMACSecret.Bytes()); got != secret {
+			t.Fatalf("Expected copies to keep secret %q; got %q", secret, got)
+		}
+		if tok.HMACSecret.buf == copied.HMACSecret.buf {
+			t.Fatalf("Expected a new secret buffer")
+		}
+	}
+}
+
+func TestSecretJSON(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	data, err := json.Marshal(tok)
+	fatal(t, err)
+	if strings.Contains(string(data), base64.StdEncoding.EncodeToString(tok.HMACSecret.Bytes())) {
+		t.Fatalf("Expected the secret to be redacted in %s", data)
+	}
+
+	var decoded Token
+	if err := json.Unmarshal(data, &decoded); !errors.Is(err, ErrSecretNotEncoded) {
+		t.Fatalf("Expected ErrSecretNotEncoded; got %v", err)
+	}
+}
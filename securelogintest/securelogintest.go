@@ -0,0 +1,156 @@
+// Package securelogintest provides utilities for testing code that verifies
+// SecureLogin tokens.
+//
+// It generates deterministic key pairs and freshly signed tokens, so tests
+// do not have to depend on hard-coded tokens which expire or belong to a
+// single provider.
+package securelogintest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vladimiroff/securelogin"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	// Origin is the provider and client of tokens created by New.
+	Origin = "https://example.com"
+
+	// EvilOrigin is the client of tokens created by Params.WrongClient.
+	EvilOrigin = "https://evil.example.com"
+)
+
+// Key is an Ed25519 key pair along with a HMAC secret.
+type Key struct {
+	PublicKey  ed25519.PublicKey
+	PrivateKey ed25519.PrivateKey
+	Secret     securelogin.Secret
+}
+
+// NewKey derives a Key from seed. The same seed always yields the same Key.
+func NewKey(seed string) Key {
+	edSeed := sha256.Sum256([]byte("ed25519:" + seed))
+	secret := sha256.Sum256([]byte("hmac:" + seed))
+	private := ed25519.NewKeyFromSeed(edSeed[:])
+
+	return Key{
+		PublicKey:  private.Public().(ed25519.PublicKey),
+		PrivateKey: private,
+		Secret:     securelogin.NewSecret(secret[:]),
+	}
+}
+
+// Params describes a token to be signed.
+type Params struct {
+	Provider string
+	Client   string
+	Scope    url.Values
+	ExpireAt time.Time
+	Email    string
+	Key      Key
+
+	badSignature bool
+	badHMAC      bool
+}
+
+// New returns Params for a sign-in token of email on Origin, which expires
+// in an hour and is signed by NewKey(email).
+func New(email string) Params {
+	return Params{
+		Provider: Origin,
+		Client:   Origin,
+		ExpireAt: time.Now().Add(time.Hour),
+		Email:    email,
+		Key:      NewKey(email),
+	}
+}
+
+// Expired returns a copy of p which has expired an hour ago.
+func (p Params) Expired() Params {
+	p.ExpireAt = time.Now().Add(-time.Hour)
+	return p
+}
+
+// WrongClient returns a copy of p issued for EvilOrigin as a client.
+func (p Params) WrongClient() Params {
+	p.Client = EvilOrigin
+	return p
+}
+
+// BadSignature returns a copy of p with corrupted Ed25519 signature.
+func (p Params) BadSignature() Params {
+	p.badSignature = true
+	return p
+}
+
+// BadHMAC returns a copy of p with corrupted HMAC signature. It still
+// verifies unless HMAC verification is enabled.
+func (p Params) BadHMAC() Params {
+	p.badHMAC = true
+	return p
+}
+
+// Change returns a copy of p in "change" mode, which requests the account
+// to be moved to the public key of to. The key is passed base64 encoded as
+// "to" in the scope.
+func (p Params) Change(to Key) Params {
+	p.Scope = url.Values{
+		"mode": []string{"change"},
+		"to":   []string{base64Encode(to.PublicKey)},
+	}
+	return p
+}
+
+// Encode signs p and returns the encoded sltoken.
+func (p Params) Encode() []byte {
+	payload := string(securelogin.MarshalPayload(securelogin.Payload{
+		Provider: p.Provider,
+		Client:   p.Client,
+		Scope:    p.Scope,
+		ExpireAt: p.ExpireAt,
+	}))
+
+	signature := ed25519.Sign(p.Key.PrivateKey, []byte(payload))
+	if p.badSignature {
+		signature[0] ^= 0xFF
+	}
+
+	mac := hmac.New(sha512.New, p.Key.Secret.Bytes())
+	mac.Write([]byte(payload))
+	hmacSignature := mac.Sum(nil)[:32]
+	if p.badHMAC {
+		hmacSignature[0] ^= 0xFF
+	}
+
+	return []byte(escapeJoin(
+		payload,
+		escapeJoin(base64Encode(signature), base64Encode(hmacSignature)),
+		escapeJoin(base64Encode(p.Key.PublicKey), base64Encode(p.Key.Secret.Bytes())),
+		p.Email,
+	))
+}
+
+// Token signs p and returns it decoded.
+func (p Params) Token() securelogin.Token {
+	t, err := securelogin.Unmarshal(p.Encode())
+	if err != nil {
+		panic("securelogintest: " + err.Error())
+	}
+	return t
+}
+
+var base64Encode = base64.StdEncoding.EncodeToString
+
+func escapeJoin(s ...string) string {
+	for i := range s {
+		s[i] = strings.Replace(s[i], ",", "%2C", -1)
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,81 @@
+package securelogintest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/vladimiroff/securelogin"
+)
+
+func TestNewKeyIsDeterministic(t *testing.T) {
+	a, b := NewKey("seed"), NewKey("seed")
+	if !bytes.Equal(a.PublicKey, b.PublicKey) || !a.Secret.Equal(b.Secret) {
+		t.Fatalf("Expected equal keys for the same seed")
+	}
+
+	c := NewKey("other")
+	if bytes.Equal(a.PublicKey, c.PublicKey) || a.Secret.Equal(c.Secret) {
+		t.Fatalf("Expected different keys for different seeds")
+	}
+}
+
+func TestVariants(t *testing.T) {
+	var (
+		o    = securelogin.WithOrigins(Origin)
+		user = New("user@example.com")
+	)
+
+	var cases = []struct {
+		params Params
+		opt    []securelogin.Option
+		err    string
+	}{
+		{user, []securelogin.Option{o}, ""},
+		{user, []securelogin.Option{o, securelogin.WithHMAC}, ""},
+		{user.Expired(), []securelogin.Option{o}, "expired token"},
+		{user.BadSignature(), []securelogin.Option{o}, "invalid signature"},
+		{user.BadHMAC(), []securelogin.Option{o}, ""},
+		{user.BadHMAC(), []securelogin.Option{o, securelogin.WithHMAC}, "invalid HMAC signature"},
+		{user.WrongClient(), []securelogin.Option{o}, "invalid client"},
+		{user.Change(NewKey("new")), []securelogin.Option{o, securelogin.WithChange}, ""},
+		{user, []securelogin.Option{o, securelogin.WithChange}, "not mode=change token"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			tok, err := securelogin.Verify(c.params.Encode(), c.opt...)
+			if c.err == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+			} else {
+				if err == nil {
+					t.Fatalf("Expected error; got nil")
+				}
+				if c.err != err.Error() {
+					t.Fatalf("Expected error %s; got %s", c.err, err)
+				}
+			}
+
+			if tok.Email != c.params.Email {
+				t.Fatalf("Expected email %s; got %s", c.params.Email, tok.Email)
+			}
+		})
+	}
+}
+
+func TestChangeTarget(t *testing.T) {
+	to := NewKey("new")
+	tok := New("user@example.com").Change(to).Token()
+
+	key, err := base64.StdEncoding.DecodeString(tok.Scope.Get("to"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !bytes.Equal(key, to.PublicKey) {
+		t.Fatalf("Expected change target to be the new key")
+	}
+}
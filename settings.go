@@ -0,0 +1,284 @@
+package securelogin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultEnvPrefix prefixes environment variables read by LoadSettings.
+const DefaultEnvPrefix = "SECURELOGIN_"
+
+// Settings is the serializable form of verification options, e.g. to be
+// read from a configuration file. Each field corresponds to an Option.
+type Settings struct {
+	// Origins as in WithOrigins.
+	Origins []string `json:"origins"`
+
+	// PublicKey as in WithPublicKey, base64 encoded.
+	PublicKey string `json:"public_key,omitempty"`
+
+	// HMACSecret as in WithSecret, base64 encoded.
+	HMACSecret string `json:"hmac_secret,omitempty"`
+
+	// HMAC as in WithHMAC.
+	HMAC bool `json:"hmac,omitempty"`
+
+	// Connect as in WithConnect.
+	Connect bool `json:"connect,omitempty"`
+
+	// Change as in WithChange.
+	Change bool `json:"change,omitempty"`
+
+	// Modes as in WithModes.
+	Modes []string `json:"modes,omitempty"`
+
+	// Scope as in WithScope.
+	Scope url.Values `json:"scope,omitempty"`
+
+	// IgnoreExpire as in WithoutExpire.
+	IgnoreExpire bool `json:"ignore_expire,omitempty"`
+
+	// Lifetimes as in WithLifetime.
+	Lifetimes []LifetimeSettings `json:"lifetimes,omitempty"`
+}
+
+// LifetimeSettings is the serializable form of WithLifetime.
+type LifetimeSettings struct {
+	Scope url.Values `json:"scope,omitempty"`
+
+	// Max is parsed by time.ParseDuration, e.g. "5m".
+	Max string `json:"max"`
+}
+
+// SettingsError points to the field or environment variable that made
+// Settings invalid.
+type SettingsError struct {
+	Field string
+	Err   error
+}
+
+func (e *SettingsError) Error() string {
+	return "settings: " + e.Field + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *SettingsError) Unwrap() error {
+	return e.Err
+}
+
+// LoadSettings reads Settings from JSON file at path, overrides them from
+// environment variables with DefaultEnvPrefix and validates them. Empty path
+// means only the environment is used.
+func LoadSettings(path string) (Settings, error) {
+	var s Settings
+
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return s, err
+		}
+		defer f.Close()
+
+		if s, err = DecodeSettings(f); err != nil {
+			return s, err
+		}
+	}
+
+	if err := s.ApplyEnv(DefaultEnvPrefix); err != nil {
+		return s, err
+	}
+
+	return s, s.Validate()
+}
+
+// DecodeSettings reads JSON encoded Settings from r. Unknown fields are
+// rejected, so typos don't go unnoticed.
+func DecodeSettings(r io.Reader) (Settings, error) {
+	var s Settings
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(&s)
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		return s, nil
+	case errors.As(err, &typeErr):
+		return s, &SettingsError{Field: typeErr.Field, Err: fmt.Errorf("expected %s, got %s", typeErr.Type, typeErr.Value)}
+	case strings.HasPrefix(err.Error(), `json: unknown field "`):
+		field := strings.TrimSuffix(strings.TrimPrefix(err.Error(), `json: unknown field "`), `"`)
+		return s, &SettingsError{Field: field, Err: errors.New("unknown field")}
+	}
+	return s, err
+}
+
+// ApplyEnv overrides s from environment variables named after the JSON
+// fields in upper case with given prefix, e.g. SECURELOGIN_HMAC. Lists are
+// comma separated and the scope is url encoded. Lifetimes can't be set from
+// the environment.
+func (s *Settings) ApplyEnv(prefix string) error {
+	return s.applyEnv(prefix, os.LookupEnv)
+}
+
+func (s *Settings) applyEnv(prefix string, lookup func(string) (string, bool)) error {
+	var (
+		err   error
+		texts = map[string]*string{
+			"PUBLIC_KEY":  &s.PublicKey,
+			"HMAC_SECRET": &s.HMACSecret,
+		}
+		lists = map[string]*[]string{
+			"ORIGINS": &s.Origins,
+			"MODES":   &s.Modes,
+		}
+		bools = map[string]*bool{
+			"HMAC":          &s.HMAC,
+			"CONNECT":       &s.Connect,
+			"CHANGE":        &s.Change,
+			"IGNORE_EXPIRE": &s.IgnoreExpire,
+		}
+	)
+
+	for name, field := range texts {
+		if v, ok := lookup(prefix + name); ok {
+			*field = v
+		}
+	}
+
+	for name, field := range lists {
+		if v, ok := lookup(prefix + name); ok {
+			*field = splitList(v)
+		}
+	}
+
+	for name, field := range bools {
+		if v, ok := lookup(prefix + name); ok {
+			if *field, err = strconv.ParseBool(v); err != nil {
+				return &SettingsError{Field: prefix + name, Err: errors.New("expected a boolean")}
+			}
+		}
+	}
+
+	if v, ok := lookup(prefix + "SCOPE"); ok {
+		if s.Scope, err = url.ParseQuery(v); err != nil {
+			return &SettingsError{Field: prefix + "SCOPE", Err: err}
+		}
+	}
+
+	return nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// Validate checks s and returns *SettingsError for the first invalid field.
+func (s Settings) Validate() error {
+	if len(s.Origins) == 0 {
+		return &SettingsError{Field: "origins", Err: errors.New("at least one origin is required")}
+	}
+
+	for i, origin := range s.Origins {
+		if err := validateOrigin(origin); err != nil {
+			return &SettingsError{Field: fmt.Sprintf("origins[%d]", i), Err: err}
+		}
+	}
+
+	if s.PublicKey != "" {
+		key, err := base64Decode(s.PublicKey)
+		if err == nil && len(key) != publicKeySize {
+			err = fmt.Errorf("expected %d bytes, got %d", publicKeySize, len(key))
+		}
+		if err != nil {
+			return &SettingsError{Field: "public_key", Err: err}
+		}
+	}
+
+	if s.HMACSecret != "" {
+		if _, err := base64Decode(s.HMACSecret); err != nil {
+			return &SettingsError{Field: "hmac_secret", Err: err}
+		}
+	}
+
+	for i, mode := range s.Modes {
+		if _, err := lookupMode(mode); err != nil {
+			return &SettingsError{Field: fmt.Sprintf("modes[%d]", i), Err: err}
+		}
+	}
+
+	for i, lifetime := range s.Lifetimes {
+		max, err := time.ParseDuration(lifetime.Max)
+		if err == nil && max <= 0 {
+			err = errors.New("must be positive")
+		}
+		if err != nil {
+			return &SettingsError{Field: fmt.Sprintf("lifetimes[%d].max", i), Err: err}
+		}
+	}
+
+	return nil
+}
+
+// validateOrigin accepts origins in the form of scheme://host[:port].
+func validateOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme == "" || u.Host == "" || u.Scheme+"://"+u.Host != origin {
+		return fmt.Errorf("%q is not in the form of scheme://host[:port]", origin)
+	}
+	return nil
+}
+
+// Options validates s and returns the equivalent options.
+func (s Settings) Options() ([]Option, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts := []Option{WithOrigins(s.Origins...), WithScope(s.Scope), WithModes(s.Modes...)}
+
+	if s.PublicKey != "" {
+		key, _ := base64Decode(s.PublicKey)
+		opts = append(opts, WithPublicKey(key))
+	}
+	if s.HMACSecret != "" {
+		secret, _ := base64Decode(s.HMACSecret)
+		opts = append(opts, WithHMACSecret(newSecret(secret)))
+	}
+	if s.HMAC {
+		opts = append(opts, WithHMAC)
+	}
+	if s.Connect {
+		opts = append(opts, WithConnect)
+	}
+	if s.Change {
+		opts = append(opts, WithChange)
+	}
+	if s.IgnoreExpire {
+		opts = append(opts, WithoutExpire)
+	}
+	for _, lifetime := range s.Lifetimes {
+		max, _ := time.ParseDuration(lifetime.Max)
+		opts = append(opts, WithLifetime(lifetime.Scope, max))
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,185 @@
+package securelogin
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeSettings(t *testing.T) {
+	s, err := DecodeSettings(strings.NewReader(`{
+		"origins": ["https://cobased.com"],
+		"hmac": true,
+		"modes": ["change"],
+		"scope": {"access": ["all"]},
+		"ignore_expire": true
+	}`))
+	fatal(t, err)
+
+	expected := Settings{
+		Origins:      []string{domain},
+		HMAC:         true,
+		Modes:        []string{"change"},
+		Scope:        accessAllScope,
+		IgnoreExpire: true,
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Fatalf("Expected %#v; got %#v", expected, s)
+	}
+}
+
+func TestDecodeSettingsErrors(t *testing.T) {
+	var cases = []struct {
+		json  string
+		field string
+	}{
+		{`{"origin": ["https://cobased.com"]}`, "origin"},
+		{`{"origins": "https://cobased.com"}`, "origins"},
+		{`{"hmac": "yes"}`, "hmac"},
+		{`{"scope": {"access": "all"}}`, "scope.access"},
+	}
+
+	for _, c := range cases {
+		_, err := DecodeSettings(strings.NewReader(c.json))
+		expectSettingsError(t, err, c.field)
+	}
+
+	if _, err := DecodeSettings(strings.NewReader(`{`)); err == nil {
+		t.Fatalf("Expected syntax error")
+	}
+}
+
+func TestSettingsValidate(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+	key := base64Encode(tok.PublicKey)
+
+	var cases = []struct {
+		settings Settings
+		field    string
+	}{
+		{Settings{Origins: []string{domain}, PublicKey: key, HMACSecret: key}, ""},
+		{Settings{Origins: []string{domain, "http://localhost:8080"}}, ""},
+		{Settings{}, "origins"},
+		{Settings{Origins: []string{domain, "cobased.com"}}, "origins[1]"},
+		{Settings{Origins: []string{domain + "/"}}, "origins[0]"},
+		{Settings{Origins: []string{domain + "/login"}}, "origins[0]"},
+		{Settings{Origins: []string{domain}, PublicKey: "!"}, "public_key"},
+		{Settings{Origins: []string{domain}, PublicKey: base64Encode(tok.PublicKey[1:])}, "public_key"},
+		{Settings{Origins: []string{domain}, HMACSecret: "!"}, "hmac_secret"},
+		{Settings{Origins: []string{domain}, Modes: []string{"change", "unknown"}}, "modes[1]"},
+		{Settings{Origins: []string{domain}, Lifetimes: []LifetimeSettings{{Max: "1m"}, {Max: "1 minute"}}}, "lifetimes[1].max"},
+		{Settings{Origins: []string{domain}, Lifetimes: []LifetimeSettings{{Max: "-1m"}}}, "lifetimes[0].max"},
+	}
+
+	for _, c := range cases {
+		err := c.settings.Validate()
+		if c.field == "" {
+			fatal(t, err)
+		} else {
+			expectSettingsError(t, err, c.field)
+		}
+
+		if _, err := c.settings.Options(); (err == nil) != (c.field == "") {
+			t.Fatalf("Expected Options to fail along with Validate; got %v", err)
+		}
+	}
+}
+
+func TestSettingsApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"SL_ORIGINS":       "https://cobased.com, https://example.com,",
+		"SL_HMAC":          "true",
+		"SL_IGNORE_EXPIRE": "1",
+		"SL_SCOPE":         "access=all",
+		"SL_PUBLIC_KEY":    "key",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	s := Settings{Origins: []string{"https://other.com"}, Connect: true}
+	fatal(t, s.applyEnv("SL_", lookup))
+
+	expected := Settings{
+		Origins:      []string{domain, "https://example.com"},
+		PublicKey:    "key",
+		HMAC:         true,
+		Connect:      true,
+		Scope:        accessAllScope,
+		IgnoreExpire: true,
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Fatalf("Expected %#v; got %#v", expected, s)
+	}
+
+	env = map[string]string{"SL_CHANGE": "maybe"}
+	expectSettingsError(t, s.applyEnv("SL_", lookup), "SL_CHANGE")
+
+	env = map[string]string{"SL_SCOPE": "%"}
+	expectSettingsError(t, s.applyEnv("SL_", lookup), "SL_SCOPE")
+}
+
+func TestLoadSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "securelogin.json")
+	err := os.WriteFile(path, []byte(`{"origins": ["https://example.com"]}`), 0600)
+	fatal(t, err)
+
+	t.Setenv(DefaultEnvPrefix+"ORIGINS", domain)
+	t.Setenv(DefaultEnvPrefix+"IGNORE_EXPIRE", "true")
+
+	s, err := LoadSettings(path)
+	fatal(t, err)
+
+	opts, err := s.Options()
+	fatal(t, err)
+	_, err = Verify([]byte(token), opts...)
+	fatal(t, err)
+
+	t.Setenv(DefaultEnvPrefix+"ORIGINS", "")
+	_, err = LoadSettings(path)
+	expectSettingsError(t, err, "origins")
+
+	if _, err := LoadSettings(path + ".missing"); !os.IsNotExist(err) {
+		t.Fatalf("Expected missing file error; got %v", err)
+	}
+}
+
+func TestSettingsOptions(t *testing.T) {
+	s := Settings{
+		Origins:      []string{domain},
+		Scope:        url.Values{"mode": []string{"change"}, "to": []string{"..."}},
+		Change:       true,
+		IgnoreExpire: true,
+		Lifetimes:    []LifetimeSettings{{Scope: accessAllScope, Max: "1m"}},
+	}
+
+	opts, err := s.Options()
+	fatal(t, err)
+
+	cfg, expected := NewConfig(opts...), NewConfig(
+		WithOrigins(domain), WithScope(s.Scope), WithChange, WithoutExpire,
+		WithLifetime(accessAllScope, time.Minute),
+	)
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Fatalf("Expected %#v; got %#v", expected, cfg)
+	}
+}
+
+func expectSettingsError(t *testing.T, err error, field string) {
+	t.Helper()
+
+	var settingsErr *SettingsError
+	if !errors.As(err, &settingsErr) {
+		t.Fatalf("Expected *SettingsError for %s; got %#v", field, err)
+	}
+	if settingsErr.Field != field {
+		t.Fatalf("Expected error in %s; got %s", field, settingsErr)
+	}
+}
@@ -0,0 +1,120 @@
+// Package slgrpc authenticates gRPC calls with SecureLogin tokens.
+//
+// Clients send the encoded token in the request metadata. The interceptors
+// verify it and expose the verified Token to handlers through the context:
+//
+//	srv := grpc.NewServer(
+//		grpc.UnaryInterceptor(slgrpc.UnaryServerInterceptor("", securelogin.WithOrigins(origin))),
+//		grpc.StreamInterceptor(slgrpc.StreamServerInterceptor("", securelogin.WithOrigins(origin))),
+//	)
+package slgrpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/vladimiroff/securelogin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultMetadataKey is the metadata key the token is read from, unless
+// another one is given to the interceptors.
+const DefaultMetadataKey = "sltoken"
+
+type tokenKey struct{}
+
+// NewContext returns a copy of ctx carrying t.
+func NewContext(ctx context.Context, t securelogin.Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, t)
+}
+
+// FromContext returns the verified token stored in ctx by the interceptors.
+func FromContext(ctx context.Context) (securelogin.Token, bool) {
+	t, ok := ctx.Value(tokenKey{}).(securelogin.Token)
+	return t, ok
+}
+
+// UnaryServerInterceptor returns an interceptor which verifies the token in
+// metadata key with opts. Empty key means DefaultMetadataKey.
+func UnaryServerInterceptor(key string, opts ...securelogin.Option) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, key, opts)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor.
+func StreamServerInterceptor(key string, opts ...securelogin.Option) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), key, opts)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ss, ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(ctx context.Context, key string, opts []securelogin.Option) (context.Context, error) {
+	if key == "" {
+		key = DefaultMetadataKey
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(key)
+	switch len(values) {
+	case 0:
+		return ctx, status.Error(codes.Unauthenticated, "missing sltoken")
+	case 1:
+	default:
+		return ctx, status.Error(codes.Unauthenticated, "multiple sltokens")
+	}
+
+	t, err := securelogin.UnmarshalString(values[0])
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if err := t.VerifyContext(ctx, opts...); err != nil {
+		return ctx, status.Error(code(err), err.Error())
+	}
+
+	return NewContext(ctx, t), nil
+}
+
+// code maps verification errors to gRPC status codes. Tokens which are not
+// authentic or expired are Unauthenticated, while authentic tokens which
+// are not accepted by the configuration are PermissionDenied.
+func code(err error) codes.Code {
+	var (
+		sigErr    *securelogin.SignatureError
+		verifyErr *securelogin.VerifyError
+	)
+
+	switch {
+	case errors.As(err, &sigErr), errors.Is(err, securelogin.ErrExpired):
+		return codes.Unauthenticated
+	case errors.As(err, &verifyErr):
+		// Verification could not be completed, rather than failed.
+		if c := status.FromContextError(verifyErr.Err).Code(); c != codes.Unknown {
+			return c
+		}
+		return codes.Unavailable
+	}
+	return codes.PermissionDenied
+}
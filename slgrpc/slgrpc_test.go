@@ -0,0 +1,116 @@
+package slgrpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vladimiroff/securelogin"
+	"github.com/vladimiroff/securelogin/securelogintest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	user = securelogintest.New("user@example.com")
+	o    = securelogin.WithOrigins(securelogintest.Origin)
+)
+
+func incoming(kv ...string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(kv...))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var cases = []struct {
+		ctx  context.Context
+		key  string
+		opts []securelogin.Option
+		code codes.Code
+	}{
+		{incoming("sltoken", string(user.Encode())), "", []securelogin.Option{o}, codes.OK},
+		{incoming("x-token", string(user.Encode())), "X-Token", []securelogin.Option{o}, codes.OK},
+		{incoming("sltoken", string(user.Encode())), "x-token", []securelogin.Option{o}, codes.Unauthenticated},
+		{context.Background(), "", []securelogin.Option{o}, codes.Unauthenticated},
+		{incoming("sltoken", "garbage"), "", []securelogin.Option{o}, codes.Unauthenticated},
+		{incoming("sltoken", "a", "sltoken", "b"), "", []securelogin.Option{o}, codes.Unauthenticated},
+		{incoming("sltoken", string(user.BadSignature().Encode())), "", []securelogin.Option{o}, codes.Unauthenticated},
+		{incoming("sltoken", string(user.Expired().Encode())), "", []securelogin.Option{o}, codes.Unauthenticated},
+		{incoming("sltoken", string(user.WrongClient().Encode())), "", []securelogin.Option{o}, codes.PermissionDenied},
+		{incoming("sltoken", string(user.Encode())), "", nil, codes.PermissionDenied},
+		{incoming("sltoken", string(user.Encode())), "", []securelogin.Option{o, securelogin.WithChange}, codes.PermissionDenied},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			var called bool
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				tok, ok := FromContext(ctx)
+				if !ok || tok.Email != user.Email {
+					t.Fatalf("Expected token of %s in context; got %#v", user.Email, tok)
+				}
+				return req, nil
+			}
+
+			_, err := UnaryServerInterceptor(c.key, c.opts...)(c.ctx, "req", &grpc.UnaryServerInfo{}, handler)
+			if code := status.Code(err); code != c.code {
+				t.Fatalf("Expected code %s; got %s (%v)", c.code, code, err)
+			}
+			if called != (c.code == codes.OK) {
+				t.Fatalf("Expected handler to be called only on success")
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptorContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(incoming("sltoken", string(user.Encode())))
+	cancel()
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return req, nil }
+	_, err := UnaryServerInterceptor("", o)(ctx, "req", &grpc.UnaryServerInfo{}, handler)
+	if code := status.Code(err); code != codes.Canceled {
+		t.Fatalf("Expected code %s; got %s", codes.Canceled, code)
+	}
+
+	ctx, cancel = context.WithDeadline(incoming("sltoken", string(user.Encode())), time.Now())
+	defer cancel()
+	_, err = UnaryServerInterceptor("", o)(ctx, "req", &grpc.UnaryServerInfo{}, handler)
+	if code := status.Code(err); code != codes.DeadlineExceeded {
+		t.Fatalf("Expected code %s; got %s", codes.DeadlineExceeded, code)
+	}
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeStream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	var email string
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		tok, _ := FromContext(ss.Context())
+		email = tok.Email
+		return nil
+	}
+
+	interceptor := StreamServerInterceptor("", o)
+	ss := fakeStream{ctx: incoming("sltoken", string(user.Encode()))}
+	if err := interceptor(nil, ss, &grpc.StreamServerInfo{}, handler); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if email != user.Email {
+		t.Fatalf("Expected token of %s in stream context; got %q", user.Email, email)
+	}
+
+	ss = fakeStream{ctx: incoming("sltoken", string(user.Expired().Encode()))}
+	err := interceptor(nil, ss, &grpc.StreamServerInfo{}, handler)
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Fatalf("Expected code %s; got %s", codes.Unauthenticated, code)
+	}
+}
@@ -0,0 +1,83 @@
+package sqlstore
+
+import (
+	"embed"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations
+var migrations embed.FS
+
+// Dialect adapts queries to a database.
+type Dialect struct {
+	name string
+
+	// numbered is set for $1, $2... placeholders instead of ?.
+	numbered bool
+
+	// onConflict turns INSERT INTO into a statement which skips rows
+	// conflicting with the primary key, whose column replaces {key}.
+	onConflict string
+}
+
+// Dialects with embedded migrations.
+var (
+	Postgres = Dialect{name: "postgres", numbered: true, onConflict: " ON CONFLICT DO NOTHING"}
+	SQLite   = Dialect{name: "sqlite", onConflict: " ON CONFLICT DO NOTHING"}
+
+	// MySQL skips conflicting rows with a no-op update rather than INSERT
+	// IGNORE, which would turn every error, e.g. truncation, into a
+	// warning. The driver must report affected rows, not found ones, as
+	// go-sql-driver/mysql does unless clientFoundRows is set.
+	MySQL = Dialect{name: "mysql", onConflict: " ON DUPLICATE KEY UPDATE {key} = {key}"}
+)
+
+// Name of the dialect, which is also the directory of its migrations.
+func (d Dialect) Name() string {
+	return d.name
+}
+
+// Migrations returns the schema migrations of d, one statement-per-semicolon
+// SQL file per version, named after it, e.g. "0001_init.sql". They are
+// applied by Store.Migrate, but may be handed to another migration tool
+// instead.
+func (d Dialect) Migrations() fs.FS {
+	sub, err := fs.Sub(migrations, "migrations/"+d.name)
+	if err != nil {
+		panic("sqlstore: " + err.Error())
+	}
+	return sub
+}
+
+// rebind replaces ? placeholders in query with the ones of d.
+func (d Dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	var (
+		b strings.Builder
+		n int
+	)
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+// insertIgnore returns an insert of values into columns of table, which
+// skips rows conflicting with the primary key. The key must be the first
+// column.
+func (d Dialect) insertIgnore(table string, columns ...string) string {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	return "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (" + placeholders + ")" +
+		strings.Replace(d.onConflict, "{key}", columns[0], -1)
+}
@@ -0,0 +1,399 @@
+package sqlstore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakeDriver is an in-process database/sql driver which understands the
+// subset of SQL used by Store: CREATE TABLE with a single column primary
+// key and inline indexes, CREATE INDEX, INSERT with either conflict clause,
+// and SELECT, UPDATE and DELETE with "=" and "<" conditions joined by AND.
+// Both ? and $N placeholders are accepted.
+type fakeDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeDB
+}
+
+func init() {
+	sql.Register("sqlstorefake", &fakeDriver{dbs: make(map[string]*fakeDB)})
+}
+
+type fakeDB struct {
+	mu     sync.Mutex
+	tables map[string]*fakeTable
+	log    []string
+}
+
+type fakeTable struct {
+	columns []string
+	primary string
+	rows    []map[string]driver.Value
+}
+
+func (t *fakeTable) clone() *fakeTable {
+	c := &fakeTable{columns: t.columns, primary: t.primary}
+	for _, row := range t.rows {
+		r := make(map[string]driver.Value, len(row))
+		for k, v := range row {
+			r[k] = v
+		}
+		c.rows = append(c.rows, r)
+	}
+	return c
+}
+
+// Open returns a connection to the database named dsn, which is created on
+// first use and shared by all connections to it.
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	db, ok := d.dbs[dsn]
+	if !ok {
+		db = &fakeDB{tables: make(map[string]*fakeTable)}
+		d.dbs[dsn] = db
+	}
+	return &fakeConn{db: db}, nil
+}
+
+func (d *fakeDriver) db(dsn string) *fakeDB {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dbs[dsn]
+}
+
+type fakeConn struct {
+	db       *fakeDB
+	snapshot map[string]*fakeTable
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fake: prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if c.snapshot != nil {
+		return nil, errors.New("fake: nested transaction")
+	}
+	c.snapshot = make(map[string]*fakeTable, len(c.db.tables))
+	for name, t := range c.db.tables {
+		c.snapshot[name] = t.clone()
+	}
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.snapshot = nil
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.db.tables, c.snapshot = c.snapshot, nil
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	_, n, err := c.run(query, args)
+	return driver.RowsAffected(n), err
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, _, err := c.run(query, args)
+	if err == nil && rows == nil {
+		return nil, errors.New("fake: statement returns no rows")
+	}
+	return rows, err
+}
+
+var (
+	spaces        = regexp.MustCompile(`\s+`)
+	createTableRe = regexp.MustCompile(`^CREATE TABLE (IF NOT EXISTS )?(\w+) \((.*)\)$`)
+	createIndexRe = regexp.MustCompile(`^CREATE INDEX `)
+	insertRe      = regexp.MustCompile(`^INSERT INTO (\w+) \(([^)]*)\) VALUES \(([^)]*)\)( ON CONFLICT DO NOTHING| ON DUPLICATE KEY UPDATE (\w+) = (\w+))?$`)
+	selectRe      = regexp.MustCompile(`^SELECT (.+?) FROM (\w+)(?: WHERE (.+))?$`)
+	updateRe      = regexp.MustCompile(`^UPDATE (\w+) SET (.+) WHERE (.+)$`)
+	deleteRe      = regexp.MustCompile(`^DELETE FROM (\w+) WHERE (.+)$`)
+	conditionRe   = regexp.MustCompile(`^(\w+) (=|<) (\?|\$\d+)$`)
+	assignmentRe  = regexp.MustCompile(`^(\w+) = (\?|\$\d+)$`)
+)
+
+// placeholders resolves ? and $N to query arguments.
+type placeholders struct {
+	args []driver.NamedValue
+	next int
+}
+
+func (p *placeholders) value(ph string) (driver.Value, error) {
+	i := p.next
+	if ph != "?" {
+		n, err := strconv.Atoi(ph[1:])
+		if err != nil {
+			return nil, err
+		}
+		i = n - 1
+	}
+	p.next++
+
+	if i < 0 || i >= len(p.args) {
+		return nil, fmt.Errorf("fake: missing argument for %s", ph)
+	}
+	return p.args[i].Value, nil
+}
+
+type condition struct {
+	column string
+	op     string
+	value  driver.Value
+}
+
+func (p *placeholders) conditions(where string) ([]condition, error) {
+	var conds []condition
+	for _, part := range strings.Split(where, " AND ") {
+		m := conditionRe.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("fake: unsupported condition %q", part)
+		}
+		v, err := p.value(m[3])
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, condition{m[1], m[2], v})
+	}
+	return conds, nil
+}
+
+func match(row map[string]driver.Value, conds []condition) bool {
+	for _, c := range conds {
+		v := row[c.column]
+		switch c.op {
+		case "=":
+			if !equal(v, c.value) {
+				return false
+			}
+		case "<":
+			a, ok1 := v.(int64)
+			b, ok2 := c.value.(int64)
+			if !ok1 || !ok2 || a >= b {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func equal(a, b driver.Value) bool {
+	ab, ok1 := a.([]byte)
+	bb, ok2 := b.([]byte)
+	if ok1 || ok2 {
+		return ok1 && ok2 && bytes.Equal(ab, bb)
+	}
+	return a == b
+}
+
+func split(list string) []string {
+	parts := strings.Split(list, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+func (c *fakeConn) run(query string, args []driver.NamedValue) (*fakeRows, int64, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	query = strings.TrimSpace(spaces.ReplaceAllString(query, " "))
+	c.db.log = append(c.db.log, query)
+	p := &placeholders{args: args}
+
+	table := func(name string) (*fakeTable, error) {
+		t, ok := c.db.tables[name]
+		if !ok {
+			return nil, fmt.Errorf("fake: no such table: %s", name)
+		}
+		return t, nil
+	}
+
+	switch {
+	case createTableRe.MatchString(query):
+		m := createTableRe.FindStringSubmatch(query)
+		if _, ok := c.db.tables[m[2]]; ok {
+			if m[1] != "" {
+				return nil, 0, nil
+			}
+			return nil, 0, fmt.Errorf("fake: table %s already exists", m[2])
+		}
+
+		t := &fakeTable{}
+		for _, def := range split(m[3]) {
+			if strings.HasPrefix(def, "INDEX ") {
+				continue
+			}
+			column := strings.Fields(def)[0]
+			t.columns = append(t.columns, column)
+			if strings.Contains(def, "PRIMARY KEY") {
+				t.primary = column
+			}
+		}
+		c.db.tables[m[2]] = t
+		return nil, 0, nil
+
+	case createIndexRe.MatchString(query):
+		return nil, 0, nil
+
+	case insertRe.MatchString(query):
+		m := insertRe.FindStringSubmatch(query)
+		if m[5] != m[6] {
+			return nil, 0, fmt.Errorf("fake: unsupported update %s = %s", m[5], m[6])
+		}
+		t, err := table(m[1])
+		if err != nil {
+			return nil, 0, err
+		}
+
+		columns, values := split(m[2]), split(m[3])
+		if len(columns) != len(values) {
+			return nil, 0, errors.New("fake: columns and values differ in count")
+		}
+		row := make(map[string]driver.Value)
+		for i, column := range columns {
+			if row[column], err = p.value(values[i]); err != nil {
+				return nil, 0, err
+			}
+		}
+
+		for _, existing := range t.rows {
+			if equal(existing[t.primary], row[t.primary]) {
+				if m[4] != "" {
+					return nil, 0, nil
+				}
+				return nil, 0, fmt.Errorf("fake: UNIQUE constraint failed: %s.%s", m[1], t.primary)
+			}
+		}
+		t.rows = append(t.rows, row)
+		return nil, 1, nil
+
+	case selectRe.MatchString(query):
+		m := selectRe.FindStringSubmatch(query)
+		t, err := table(m[2])
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var conds []condition
+		if m[3] != "" {
+			if conds, err = p.conditions(m[3]); err != nil {
+				return nil, 0, err
+			}
+		}
+
+		rows := &fakeRows{columns: split(m[1])}
+		for _, row := range t.rows {
+			if !match(row, conds) {
+				continue
+			}
+			values := make([]driver.Value, len(rows.columns))
+			for i, column := range rows.columns {
+				values[i] = row[column]
+			}
+			rows.rows = append(rows.rows, values)
+		}
+		return rows, 0, nil
+
+	case updateRe.MatchString(query):
+		m := updateRe.FindStringSubmatch(query)
+		t, err := table(m[1])
+		if err != nil {
+			return nil, 0, err
+		}
+
+		set := make(map[string]driver.Value)
+		for _, part := range split(m[2]) {
+			a := assignmentRe.FindStringSubmatch(part)
+			if a == nil {
+				return nil, 0, fmt.Errorf("fake: unsupported assignment %q", part)
+			}
+			if set[a[1]], err = p.value(a[2]); err != nil {
+				return nil, 0, err
+			}
+		}
+		conds, err := p.conditions(m[3])
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var n int64
+		for _, row := range t.rows {
+			if match(row, conds) {
+				for k, v := range set {
+					row[k] = v
+				}
+				n++
+			}
+		}
+		return nil, n, nil
+
+	case deleteRe.MatchString(query):
+		m := deleteRe.FindStringSubmatch(query)
+		t, err := table(m[1])
+		if err != nil {
+			return nil, 0, err
+		}
+		conds, err := p.conditions(m[2])
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var (
+			kept []map[string]driver.Value
+			n    int64
+		)
+		for _, row := range t.rows {
+			if match(row, conds) {
+				n++
+			} else {
+				kept = append(kept, row)
+			}
+		}
+		t.rows = kept
+		return nil, n, nil
+	}
+
+	return nil, 0, fmt.Errorf("fake: unsupported statement %q", query)
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,30 @@
+-- Times are unix nanoseconds.
+--
+-- MySQL commits schema changes implicitly, so statements only create what
+-- doesn't exist yet and a failed migration can be retried. Indexes are
+-- declared inline, as CREATE INDEX has no IF NOT EXISTS in MySQL.
+
+CREATE TABLE IF NOT EXISTS securelogin_accounts (
+	email VARCHAR(320) PRIMARY KEY,
+	public_key VARBINARY(64) NOT NULL,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS securelogin_nonces (
+	nonce VARCHAR(64) PRIMARY KEY,
+	session VARCHAR(255) NOT NULL,
+	expire_at BIGINT NOT NULL,
+	INDEX securelogin_nonces_expire_at (expire_at)
+);
+
+CREATE TABLE IF NOT EXISTS securelogin_used_tokens (
+	hash CHAR(64) PRIMARY KEY,
+	expire_at BIGINT NOT NULL,
+	INDEX securelogin_used_tokens_expire_at (expire_at)
+);
+
+CREATE TABLE IF NOT EXISTS securelogin_revoked_keys (
+	fingerprint CHAR(64) PRIMARY KEY,
+	revoked_at BIGINT NOT NULL
+);
@@ -0,0 +1,28 @@
+-- Times are unix nanoseconds.
+
+CREATE TABLE securelogin_accounts (
+	email VARCHAR(320) PRIMARY KEY,
+	public_key BLOB NOT NULL,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL
+);
+
+CREATE TABLE securelogin_nonces (
+	nonce VARCHAR(64) PRIMARY KEY,
+	session TEXT NOT NULL,
+	expire_at BIGINT NOT NULL
+);
+
+CREATE INDEX securelogin_nonces_expire_at ON securelogin_nonces (expire_at);
+
+CREATE TABLE securelogin_used_tokens (
+	hash CHAR(64) PRIMARY KEY,
+	expire_at BIGINT NOT NULL
+);
+
+CREATE INDEX securelogin_used_tokens_expire_at ON securelogin_used_tokens (expire_at);
+
+CREATE TABLE securelogin_revoked_keys (
+	fingerprint CHAR(64) PRIMARY KEY,
+	revoked_at BIGINT NOT NULL
+);
@@ -0,0 +1,351 @@
+// Package sqlstore keeps SecureLogin credentials, nonces, used tokens and
+// revoked keys in a database/sql database.
+//
+// Store implements accounts.Store and securelogin.NonceStore, so it can back
+// both:
+//
+//	store := sqlstore.New(db, sqlstore.Postgres)
+//	if err := store.Migrate(ctx); err != nil {
+//		...
+//	}
+//	accts := accounts.New(store, securelogin.WithOrigins(origin))
+//
+// Tables are prefixed with "securelogin_" and times are stored as unix
+// nanoseconds, so the schema is the same across dialects. Times past what
+// they can hold, the year 2262, are stored as the largest one.
+package sqlstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vladimiroff/securelogin"
+	"github.com/vladimiroff/securelogin/accounts"
+)
+
+var (
+	// ErrReplayed is returned by MarkUsed for tokens used before.
+	ErrReplayed = errors.New("sqlstore: token already used")
+
+	// ErrRevoked is returned by MarkUsed for tokens signed by a revoked
+	// key.
+	ErrRevoked = errors.New("sqlstore: key revoked")
+)
+
+var (
+	_ accounts.Store         = (*Store)(nil)
+	_ securelogin.NonceStore = (*Store)(nil)
+)
+
+// Store keeps SecureLogin state in a database. It's safe for concurrent use
+// as long as the database is.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	now     func() time.Time
+}
+
+// New returns a Store keeping its state in db, which speaks dialect.
+func New(db *sql.DB, dialect Dialect) *Store {
+	return &Store{db: db, dialect: dialect, now: time.Now}
+}
+
+var (
+	minTime = time.Unix(0, math.MinInt64)
+	maxTime = time.Unix(0, math.MaxInt64)
+)
+
+// unixNano returns t in unix nanoseconds, clamped to what int64 holds, so
+// that far future times, e.g. expirations chosen by clients, don't overflow
+// into the past.
+func unixNano(t time.Time) int64 {
+	switch {
+	case t.After(maxTime):
+		return math.MaxInt64
+	case t.Before(minTime):
+		return math.MinInt64
+	}
+	return t.UnixNano()
+}
+
+func (s *Store) exec(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	res, err := s.db.ExecContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *Store) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRowContext(ctx, s.dialect.rebind(query), args...)
+}
+
+// Migrate applies the migrations of the dialect, which were not applied
+// yet, each in a transaction. Applied versions are kept in the
+// securelogin_migrations table.
+//
+// MySQL commits schema changes implicitly, so there a failed migration may
+// leave tables behind without its version being recorded. Its migrations
+// only create what doesn't exist yet, so that Migrate can be retried.
+func (s *Store) Migrate(ctx context.Context) error {
+	const create = `CREATE TABLE IF NOT EXISTS securelogin_migrations (
+	version BIGINT PRIMARY KEY,
+	applied_at BIGINT NOT NULL
+)`
+	if _, err := s.db.ExecContext(ctx, create); err != nil {
+		return err
+	}
+
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(s.dialect.Migrations(), "*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, name := range files {
+		version, err := strconv.ParseInt(strings.SplitN(name, "_", 2)[0], 10, 64)
+		if err != nil {
+			return errors.New("sqlstore: invalid migration name " + name)
+		}
+		if applied[version] {
+			continue
+		}
+
+		script, err := fs.ReadFile(s.dialect.Migrations(), name)
+		if err != nil {
+			return err
+		}
+		if err := s.migrate(ctx, version, string(script)); err != nil {
+			return errors.New("sqlstore: migration " + name + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) appliedMigrations(ctx context.Context) (map[int64]bool, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT version FROM securelogin_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+func (s *Store) migrate(ctx context.Context, version int64, script string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range statements(script) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	insert := s.dialect.rebind("INSERT INTO securelogin_migrations (version, applied_at) VALUES (?, ?)")
+	if _, err := tx.ExecContext(ctx, insert, version, s.now().UnixNano()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// statements splits script on semicolons ending a line and drops comments,
+// as not every driver executes several statements at once.
+func statements(script string) []string {
+	var (
+		stmts   []string
+		current strings.Builder
+	)
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteByte('\n')
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+
+	return stmts
+}
+
+// Create implements accounts.Store.
+func (s *Store) Create(ctx context.Context, a accounts.Account) error {
+	query := s.dialect.insertIgnore("securelogin_accounts", "email", "public_key", "created_at", "updated_at")
+
+	n, err := s.exec(ctx, query, a.Email, a.PublicKey, unixNano(a.CreatedAt), unixNano(a.UpdatedAt))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return accounts.ErrExists
+	}
+	return nil
+}
+
+// Get implements accounts.Store.
+func (s *Store) Get(ctx context.Context, email string) (accounts.Account, error) {
+	var (
+		a                    = accounts.Account{Email: email}
+		createdAt, updatedAt int64
+	)
+
+	err := s.queryRow(ctx, "SELECT public_key, created_at, updated_at FROM securelogin_accounts WHERE email = ?", email).
+		Scan(&a.PublicKey, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return accounts.Account{}, accounts.ErrNotFound
+	}
+	if err != nil {
+		return accounts.Account{}, err
+	}
+
+	a.CreatedAt, a.UpdatedAt = time.Unix(0, createdAt), time.Unix(0, updatedAt)
+	return a, nil
+}
+
+// UpdateKey implements accounts.Store.
+func (s *Store) UpdateKey(ctx context.Context, email string, from, to []byte, at time.Time) error {
+	n, err := s.exec(ctx,
+		"UPDATE securelogin_accounts SET public_key = ?, updated_at = ? WHERE email = ? AND public_key = ?",
+		to, unixNano(at), email, from)
+	if err != nil || n > 0 {
+		return err
+	}
+
+	a, err := s.Get(ctx, email)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(a.PublicKey, to) {
+		// Some databases report only rows which actually changed.
+		return nil
+	}
+	return accounts.ErrKeyChanged
+}
+
+// Put implements securelogin.NonceStore.
+func (s *Store) Put(ctx context.Context, nonce, session string, expireAt time.Time) error {
+	_, err := s.exec(ctx,
+		"INSERT INTO securelogin_nonces (nonce, session, expire_at) VALUES (?, ?, ?)",
+		nonce, session, unixNano(expireAt))
+	return err
+}
+
+// Take implements securelogin.NonceStore. Only one of concurrent callers
+// deletes the nonce, and only that one succeeds.
+func (s *Store) Take(ctx context.Context, nonce string) (string, time.Time, error) {
+	var (
+		session  string
+		expireAt int64
+	)
+
+	err := s.queryRow(ctx, "SELECT session, expire_at FROM securelogin_nonces WHERE nonce = ?", nonce).
+		Scan(&session, &expireAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", time.Time{}, securelogin.ErrNonceNotFound
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	n, err := s.exec(ctx, "DELETE FROM securelogin_nonces WHERE nonce = ?", nonce)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if n == 0 {
+		return "", time.Time{}, securelogin.ErrNonceNotFound
+	}
+
+	return session, time.Unix(0, expireAt), nil
+}
+
+// MarkUsed records a verified token as used, so that it's accepted only once
+// until it expires. It fails with ErrReplayed if it was used before, and with
+// ErrRevoked if it's signed by a revoked key.
+func (s *Store) MarkUsed(ctx context.Context, t securelogin.Token) error {
+	revoked, err := s.Revoked(ctx, t.Fingerprint())
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrRevoked
+	}
+
+	// Ed25519 signatures are deterministic, so the signature identifies a
+	// token.
+	hash := sha256.Sum256(t.Signature)
+
+	query := s.dialect.insertIgnore("securelogin_used_tokens", "hash", "expire_at")
+	n, err := s.exec(ctx, query, hex.EncodeToString(hash[:]), unixNano(t.ExpireAt))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// Revoke marks the public key of fingerprint as revoked. Revoking a key
+// twice keeps the time of the first revocation.
+func (s *Store) Revoke(ctx context.Context, f securelogin.Fingerprint) error {
+	query := s.dialect.insertIgnore("securelogin_revoked_keys", "fingerprint", "revoked_at")
+	_, err := s.exec(ctx, query, f.String(), s.now().UnixNano())
+	return err
+}
+
+// Revoked reports whether the public key of fingerprint is revoked.
+func (s *Store) Revoked(ctx context.Context, f securelogin.Fingerprint) (bool, error) {
+	var revokedAt int64
+
+	err := s.queryRow(ctx, "SELECT revoked_at FROM securelogin_revoked_keys WHERE fingerprint = ?", f.String()).
+		Scan(&revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Cleanup deletes nonces and used tokens which expired before now.
+func (s *Store) Cleanup(ctx context.Context, now time.Time) error {
+	if _, err := s.exec(ctx, "DELETE FROM securelogin_nonces WHERE expire_at < ?", unixNano(now)); err != nil {
+		return err
+	}
+	_, err := s.exec(ctx, "DELETE FROM securelogin_used_tokens WHERE expire_at < ?", unixNano(now))
+	return err
+}
@@ -0,0 +1,412 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vladimiroff/securelogin"
+	"github.com/vladimiroff/securelogin/accounts"
+	"github.com/vladimiroff/securelogin/securelogintest"
+)
+
+var (
+	ctx      = context.Background()
+	dialects = []Dialect{Postgres, SQLite, MySQL}
+)
+
+func newStore(t *testing.T, d Dialect) (*Store, *fakeDB) {
+	dsn := d.Name() + "/" + t.Name()
+	db, err := sql.Open("sqlstorefake", dsn)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s := New(db, d)
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return s, db.Driver().(*fakeDriver).db(dsn)
+}
+
+// forEachDialect runs f against a migrated store of every dialect.
+func forEachDialect(t *testing.T, f func(t *testing.T, s *Store)) {
+	for _, d := range dialects {
+		t.Run(d.Name(), func(t *testing.T) {
+			s, _ := newStore(t, d)
+			f(t, s)
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	for _, d := range dialects {
+		t.Run(d.Name(), func(t *testing.T) {
+			s, db := newStore(t, d)
+			if err := s.Migrate(ctx); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			created := 0
+			for _, q := range db.log {
+				q = strings.Replace(q, "IF NOT EXISTS ", "", 1)
+				if strings.HasPrefix(q, "CREATE TABLE securelogin_accounts") {
+					created++
+				}
+			}
+			if created != 1 {
+				t.Fatalf("Expected migration to be applied once; got %d", created)
+			}
+
+			for _, table := range []string{"securelogin_accounts", "securelogin_nonces", "securelogin_used_tokens", "securelogin_revoked_keys"} {
+				if _, ok := db.tables[table]; !ok {
+					t.Errorf("Expected table %s", table)
+				}
+			}
+			if rows := db.tables["securelogin_migrations"].rows; len(rows) != 1 || rows[0]["version"] != int64(1) {
+				t.Fatalf("Expected version 1 to be recorded; got %v", rows)
+			}
+		})
+	}
+}
+
+func TestMigrationsPerDialect(t *testing.T) {
+	var schemas []string
+	for _, d := range dialects {
+		data, err := fs.ReadFile(d.Migrations(), "0001_init.sql")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", d.Name(), err)
+		}
+		schemas = append(schemas, string(data))
+	}
+
+	if !strings.Contains(schemas[0], "BYTEA") || !strings.Contains(schemas[1], "BLOB") || !strings.Contains(schemas[2], "VARBINARY") {
+		t.Fatalf("Expected dialect specific key columns")
+	}
+}
+
+func TestMigrateRollsBack(t *testing.T) {
+	db, err := sql.Open("sqlstorefake", "broken/"+t.Name())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	// A table left from elsewhere makes the migration fail halfway.
+	if _, err := db.Exec("CREATE TABLE securelogin_nonces (nonce VARCHAR(64) PRIMARY KEY)"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	s := New(db, SQLite)
+	if err := s.Migrate(ctx); err == nil {
+		t.Fatalf("Expected error; got nil")
+	}
+
+	fake := db.Driver().(*fakeDriver).db("broken/" + t.Name())
+	if _, ok := fake.tables["securelogin_accounts"]; ok {
+		t.Fatalf("Expected failed migration to be rolled back")
+	}
+	if rows := fake.tables["securelogin_migrations"].rows; len(rows) != 0 {
+		t.Fatalf("Expected no version to be recorded; got %v", rows)
+	}
+}
+
+func TestMigrateRetriesMySQL(t *testing.T) {
+	db, err := sql.Open("sqlstorefake", "partial/"+t.Name())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	// MySQL keeps the tables a failed migration created.
+	script, err := fs.ReadFile(MySQL.Migrations(), "0001_init.sql")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, stmt := range statements(string(script))[:2] {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	s := New(db, MySQL)
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	fake := db.Driver().(*fakeDriver).db("partial/" + t.Name())
+	if rows := fake.tables["securelogin_migrations"].rows; len(rows) != 1 || rows[0]["version"] != int64(1) {
+		t.Fatalf("Expected version 1 to be recorded; got %v", rows)
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	const query = "UPDATE t SET a = ? WHERE b = ? AND c = ?"
+
+	var cases = []struct {
+		d        Dialect
+		expected string
+	}{
+		{Postgres, "UPDATE t SET a = $1 WHERE b = $2 AND c = $3"},
+		{SQLite, query},
+		{MySQL, query},
+	}
+
+	for _, c := range cases {
+		if got := c.d.rebind(query); got != c.expected {
+			t.Errorf("%s: expected %q; got %q", c.d.Name(), c.expected, got)
+		}
+	}
+
+	if got := MySQL.insertIgnore("t", "a", "b"); got != "INSERT INTO t (a, b) VALUES (?, ?) ON DUPLICATE KEY UPDATE a = a" {
+		t.Errorf("Unexpected MySQL insert %q", got)
+	}
+	if got := Postgres.rebind(Postgres.insertIgnore("t", "a")); got != "INSERT INTO t (a) VALUES ($1) ON CONFLICT DO NOTHING" {
+		t.Errorf("Unexpected Postgres insert %q", got)
+	}
+}
+
+func TestStatements(t *testing.T) {
+	script := "-- comment\n\nCREATE TABLE a (\n\tx INT\n);\n\nCREATE INDEX i ON a (x);\nDROP TABLE b"
+	expected := []string{"CREATE TABLE a (\n\tx INT\n)", "CREATE INDEX i ON a (x)", "DROP TABLE b"}
+
+	if got := statements(script); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %q; got %q", expected, got)
+	}
+}
+
+func TestAccountsStore(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, s *Store) {
+		var (
+			now = time.Unix(0, time.Now().UnixNano())
+			a   = accounts.Account{Email: "homer@example.com", PublicKey: []byte("key"), CreatedAt: now, UpdatedAt: now}
+		)
+
+		if _, err := s.Get(ctx, a.Email); !errors.Is(err, accounts.ErrNotFound) {
+			t.Fatalf("Expected ErrNotFound; got %v", err)
+		}
+		if err := s.Create(ctx, a); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if err := s.Create(ctx, a); !errors.Is(err, accounts.ErrExists) {
+			t.Fatalf("Expected ErrExists; got %v", err)
+		}
+
+		got, err := s.Get(ctx, a.Email)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(got, a) {
+			t.Fatalf("Expected %+v; got %+v", a, got)
+		}
+
+		later := now.Add(time.Minute)
+		if err := s.UpdateKey(ctx, a.Email, []byte("other"), []byte("new"), later); !errors.Is(err, accounts.ErrKeyChanged) {
+			t.Fatalf("Expected ErrKeyChanged; got %v", err)
+		}
+		if err := s.UpdateKey(ctx, "bart@example.com", a.PublicKey, []byte("new"), later); !errors.Is(err, accounts.ErrNotFound) {
+			t.Fatalf("Expected ErrNotFound; got %v", err)
+		}
+		if err := s.UpdateKey(ctx, a.Email, a.PublicKey, []byte("new"), later); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		got, _ = s.Get(ctx, a.Email)
+		if string(got.PublicKey) != "new" || !got.UpdatedAt.Equal(later) || !got.CreatedAt.Equal(now) {
+			t.Fatalf("Unexpected account after update %+v", got)
+		}
+	})
+}
+
+func TestAccountsService(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, s *Store) {
+		var (
+			a      = accounts.New(s, securelogin.WithOrigins(securelogintest.Origin))
+			homer  = securelogintest.New("homer@example.com")
+			newKey = securelogintest.NewKey("new")
+		)
+
+		if _, err := a.Register(ctx, homer.Encode()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if _, err := a.Register(ctx, homer.Encode()); !errors.Is(err, accounts.ErrExists) {
+			t.Fatalf("Expected ErrExists; got %v", err)
+		}
+		if _, err := a.Login(ctx, homer.Encode()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if _, err := a.Change(ctx, homer.Change(newKey).Encode()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if _, err := a.Login(ctx, homer.Encode()); err == nil {
+			t.Fatalf("Expected error; got nil")
+		}
+
+		homer.Key = newKey
+		if _, err := a.Login(ctx, homer.Encode()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	})
+}
+
+func TestNonceStore(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, s *Store) {
+		nonce, err := securelogin.NewNonce(ctx, s, "session", time.Minute)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		p := securelogintest.New("homer@example.com")
+		p.Scope = url.Values{securelogin.NonceKey: {nonce}}
+		if _, err := securelogin.Verify(p.Encode(), securelogin.WithOrigins(securelogintest.Origin), securelogin.WithNonce(s, "session")); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if _, err := securelogin.Verify(p.Encode(), securelogin.WithOrigins(securelogintest.Origin), securelogin.WithNonce(s, "session")); err == nil {
+			t.Fatalf("Expected a nonce to be accepted once")
+		}
+
+		if _, _, err := s.Take(ctx, "unknown"); !errors.Is(err, securelogin.ErrNonceNotFound) {
+			t.Fatalf("Expected ErrNonceNotFound; got %v", err)
+		}
+	})
+}
+
+func TestNonceTakeOnce(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, s *Store) {
+		expireAt := time.Unix(0, time.Now().Add(time.Minute).UnixNano())
+		if err := s.Put(ctx, "nonce", "session", expireAt); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			taken int
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				session, at, err := s.Take(ctx, "nonce")
+				if err == nil {
+					mu.Lock()
+					taken++
+					mu.Unlock()
+					if session != "session" || !at.Equal(expireAt) {
+						t.Errorf("Unexpected nonce %q expiring at %s", session, at)
+					}
+				} else if !errors.Is(err, securelogin.ErrNonceNotFound) {
+					t.Errorf("Unexpected error: %s", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if taken != 1 {
+			t.Fatalf("Expected nonce to be taken once; got %d", taken)
+		}
+	})
+}
+
+func TestReplay(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, s *Store) {
+		tok := securelogintest.New("homer@example.com").Token()
+		other := securelogintest.New("bart@example.com").Token()
+
+		if err := s.MarkUsed(ctx, tok); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if err := s.MarkUsed(ctx, tok); !errors.Is(err, ErrReplayed) {
+			t.Fatalf("Expected ErrReplayed; got %v", err)
+		}
+		if err := s.MarkUsed(ctx, other); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		// Used tokens are forgotten once they expire.
+		if err := s.Cleanup(ctx, time.Now()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if err := s.MarkUsed(ctx, tok); !errors.Is(err, ErrReplayed) {
+			t.Fatalf("Expected ErrReplayed before expiration; got %v", err)
+		}
+		if err := s.Cleanup(ctx, tok.ExpireAt.Add(time.Second)); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if err := s.MarkUsed(ctx, tok); err != nil {
+			t.Fatalf("Unexpected error after cleanup: %s", err)
+		}
+
+		// Expirations past what unix nanoseconds hold are kept as the
+		// latest one rather than overflowing into the past.
+		far := securelogintest.New("lisa@example.com")
+		far.ExpireAt = time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)
+		if err := s.MarkUsed(ctx, far.Token()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if err := s.Cleanup(ctx, time.Now()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if err := s.MarkUsed(ctx, far.Token()); !errors.Is(err, ErrReplayed) {
+			t.Fatalf("Expected ErrReplayed for far expiration; got %v", err)
+		}
+	})
+}
+
+func TestRevocation(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, s *Store) {
+		tok := securelogintest.New("homer@example.com").Token()
+
+		revoked, err := s.Revoked(ctx, tok.Fingerprint())
+		if err != nil || revoked {
+			t.Fatalf("Expected key not to be revoked; got %t, %v", revoked, err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := s.Revoke(ctx, tok.Fingerprint()); err != nil {
+				t.Fatalf("%d: unexpected error: %s", i, err)
+			}
+		}
+
+		revoked, err = s.Revoked(ctx, tok.Fingerprint())
+		if err != nil || !revoked {
+			t.Fatalf("Expected key to be revoked; got %t, %v", revoked, err)
+		}
+		if err := s.MarkUsed(ctx, tok); !errors.Is(err, ErrRevoked) {
+			t.Fatalf("Expected ErrRevoked; got %v", err)
+		}
+	})
+}
+
+func TestDialectQueries(t *testing.T) {
+	for _, d := range dialects {
+		t.Run(d.Name(), func(t *testing.T) {
+			s, db := newStore(t, d)
+			if err := s.MarkUsed(ctx, securelogintest.New("homer@example.com").Token()); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			insert := db.log[len(db.log)-1]
+			var expected string
+			switch d.Name() {
+			case "postgres":
+				expected = "INSERT INTO securelogin_used_tokens (hash, expire_at) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+			case "sqlite":
+				expected = "INSERT INTO securelogin_used_tokens (hash, expire_at) VALUES (?, ?) ON CONFLICT DO NOTHING"
+			case "mysql":
+				expected = "INSERT INTO securelogin_used_tokens (hash, expire_at) VALUES (?, ?) ON DUPLICATE KEY UPDATE hash = hash"
+			}
+			if insert != expected {
+				t.Fatalf("Expected %q; got %q", expected, insert)
+			}
+		})
+	}
+}
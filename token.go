@@ -7,7 +7,7 @@
 package securelogin
 
 import (
-	"errors"
+	"context"
 	"net/url"
 	"time"
 )
@@ -15,6 +15,9 @@ import (
 // Token is the core of SecureLogin Protocol.
 type Token struct {
 
+	// Version of the token format. Zero means Version1.
+	Version int
+
 	// rawPayload is the first argument before decoding. It's being used
 	// for shared secret and Ed25519 signature verifying.
 	rawPayload []byte
@@ -40,8 +43,9 @@ type Token struct {
 	PublicKey []byte
 
 	// HMACSecret is the key used to sign the payload. Could be overridden
-	// by options during verification.
-	HMACSecret []byte
+	// by options during verification. It's left out by Marshal, see
+	// MarshalWithSecret.
+	HMACSecret Secret
 
 	//Signature to be verified by the Ed25519 signature algorithm.
 	Signature []byte
@@ -54,40 +58,190 @@ type Token struct {
 	Email string
 }
 
+// Result of a successful verification.
+type Result struct {
+	// Token that has been verified.
+	Token Token
+
+	// Mode of the token. Empty unless the token was verified by a
+	// registered Mode.
+	Mode string
+
+	// Value returned by Mode.Result, e.g. ChangeResult for "change".
+	Value interface{}
+
+	// Fingerprint of the public key the signature was verified against.
+	Fingerprint Fingerprint
+
+	// PublicKeyIndex is the index of the key in WithPublicKeys which
+	// matched, or -1 if the option was not used.
+	PublicKeyIndex int
+
+	// SecretIndex is the index of the secret in WithSecrets which matched,
+	// or -1 if the option was not used.
+	SecretIndex int
+}
+
+// Wipe clears the HMAC secret of t, along with every copy of t, see
+// Secret.Wipe.
+func (t Token) Wipe() {
+	t.HMACSecret.Wipe()
+}
+
+// Fingerprint returns the fingerprint of the token's public key.
+func (t Token) Fingerprint() Fingerprint {
+	return NewFingerprint(t.PublicKey)
+}
+
 // Verify token with given options.
 func (t Token) Verify(opts ...Option) error {
-	var cfg = NewConfig(opts...)
+	return t.VerifyContext(context.Background(), opts...)
+}
+
+// VerifyContext verifies token with given options like Verify does. The
+// context is passed to every store looked up during verification and is
+// checked between stages. If it's done, the returned error is a *VerifyError
+// wrapping ctx.Err().
+func (t Token) VerifyContext(ctx context.Context, opts ...Option) error {
+	_, err := t.VerifyResultContext(ctx, opts...)
+	return err
+}
+
+// VerifyResult verifies token with given options like Verify does, and
+// returns the outcome of it.
+func (t Token) VerifyResult(opts ...Option) (Result, error) {
+	return t.VerifyResultContext(context.Background(), opts...)
+}
+
+// VerifyResultContext is VerifyResult with a context, see VerifyContext.
+func (t Token) VerifyResultContext(ctx context.Context, opts ...Option) (Result, error) {
+	return t.verify(ctx, NewConfig(opts...))
+}
+
+func (t Token) verify(ctx context.Context, cfg Config) (Result, error) {
+	var (
+		now    = time.Now().UTC()
+		result = Result{Token: t, PublicKeyIndex: -1, SecretIndex: -1}
+	)
 
 	if len(cfg.publicKey) > 0 {
 		t.PublicKey = cfg.publicKey
 	}
 
-	if !verifySignature(t.rawPayload, t.Signature, t.PublicKey) {
-		return errors.New("invalid signature")
+	if err := checkpoint(ctx, "signature"); err != nil {
+		return result, err
 	}
 
+	algs, err := lookupVersion(t.Version)
+	if err != nil {
+		return result, err
+	}
+	message := t.signedMessage()
+
+	if result.PublicKeyIndex, err = t.checkSignature(cfg, algs, message, now); err != nil {
+		return result, err
+	}
+	if result.PublicKeyIndex >= 0 {
+		t.PublicKey = cfg.publicKeys[result.PublicKeyIndex].Key
+	}
+	result.Fingerprint = t.Fingerprint()
+
 	if cfg.hmac {
-		if len(cfg.hmacSecret) > 0 {
-			t.HMACSecret = cfg.hmacSecret
+		if result.SecretIndex, err = t.checkHMAC(cfg, algs, message, now); err != nil {
+			return result, err
 		}
-		if !verifyHMAC(t.rawPayload, t.HMACSignature, t.HMACSecret) {
-			return errors.New("invalid HMAC signature")
+	}
+
+	if err := checkpoint(ctx, "origin"); err != nil {
+		return result, err
+	}
+
+	if !cfg.hasOrigin(t.Provider) {
+		return result, ErrInvalidProvider
+	}
+
+	if !cfg.connect && !cfg.hasOrigin(t.Client) {
+		return result, ErrInvalidClient
+	}
+
+	if cfg.expire {
+		if now.After(t.ExpireAt) {
+			return result, ErrExpired
+		}
+		if err := verifyLifetime(cfg, t, now); err != nil {
+			return result, err
 		}
 	}
 
-	if _, ok := cfg.origins[t.Provider]; !ok {
-		return errors.New("invalid provider")
+	if err := checkpoint(ctx, "scope"); err != nil {
+		return result, err
+	}
+
+	mode, err := verifyScope(cfg, t.Scope)
+	if err != nil {
+		return result, err
+	}
+
+	if err := checkpoint(ctx, "nonce"); err != nil {
+		return result, err
+	}
+
+	if err := verifyNonce(ctx, cfg, t.Scope); err != nil {
+		return result, err
 	}
 
-	if !cfg.connect {
-		if _, ok := cfg.origins[t.Client]; !ok {
-			return errors.New("invalid client")
+	if mode != "" {
+		m, err := lookupMode(mode)
+		if err != nil {
+			return result, err
+		}
+		result.Mode = mode
+		if result.Value, err = m.Result(t); err != nil {
+			return result, err
 		}
 	}
 
-	if cfg.expire && time.Now().UTC().After(t.ExpireAt) {
-		return errors.New("expired token")
+	return result, nil
+}
+
+// checkSignature verifies the Ed25519 signature of t and returns the index of
+// the key in WithPublicKeys which matched, or -1 if the option was not used.
+func (t Token) checkSignature(cfg Config, algs Algorithms, message []byte, now time.Time) (int, error) {
+	if len(cfg.publicKeys) > 0 {
+		i := matchKey(cfg.publicKeys, now, func(key []byte) bool {
+			return algs.Signature.Verify(key, message, t.Signature)
+		})
+		if i < 0 {
+			return i, &SignatureError{Fingerprint: t.Fingerprint()}
+		}
+		return i, nil
 	}
 
-	return verifyScope(cfg, t.Scope)
+	if !algs.Signature.Verify(t.PublicKey, message, t.Signature) {
+		return -1, &SignatureError{Fingerprint: t.Fingerprint()}
+	}
+	return -1, nil
+}
+
+// checkHMAC verifies the HMAC signature of t and returns the index of the
+// secret in WithSecrets which matched, or -1 if the option was not used.
+func (t Token) checkHMAC(cfg Config, algs Algorithms, message []byte, now time.Time) (int, error) {
+	if cfg.hmacSecret.Len() > 0 {
+		t.HMACSecret = cfg.hmacSecret
+	}
+
+	if len(cfg.secrets) > 0 {
+		i := matchKey(secretKeys(cfg.secrets), now, func(secret []byte) bool {
+			return verifyMAC(algs.MAC, message, t.HMACSignature, secret)
+		})
+		if i < 0 {
+			return i, &SignatureError{Fingerprint: t.Fingerprint(), HMAC: true}
+		}
+		return i, nil
+	}
+
+	if !verifyMAC(algs.MAC, message, t.HMACSignature, t.HMACSecret.Bytes()) {
+		return -1, &SignatureError{Fingerprint: t.Fingerprint(), HMAC: true}
+	}
+	return -1, nil
 }
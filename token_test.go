@@ -56,7 +56,7 @@ func TestTokenVerify(t *testing.T) {
 
 	token, err := UnmarshalString(token)
 	if err != nil {
-		t.Skipf("UnmarshalToken has failed with %q, skipping Verify")
+		t.Skipf("UnmarshalToken has failed with %q, skipping Verify", err)
 	}
 
 	for i, c := range cases {
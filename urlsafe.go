@@ -0,0 +1,107 @@
+package securelogin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// URLPrefix starts tokens in the URL-safe form.
+const URLPrefix = "sl."
+
+// MarshalURL returns t in the URL-safe form, which is URLPrefix followed by
+// the compact binary encoding in unpadded base64url, see MarshalBinary. It's
+// made of unreserved characters only, so it survives query strings and
+// fragments unescaped. Unmarshal accepts it along with the standard form.
+// The HMAC secret is left out, see MarshalURLWithSecret.
+func MarshalURL(t Token) string {
+	return encodeURL(appendBinary(nil, t, false))
+}
+
+// MarshalURLWithSecret returns t in the URL-safe form including its HMAC
+// secret, see MarshalURL.
+func MarshalURLWithSecret(t Token) string {
+	return encodeURL(appendBinary(nil, t, true))
+}
+
+func encodeURL(data []byte) string {
+	return URLPrefix + base64.RawURLEncoding.EncodeToString(data)
+}
+
+// isURLSafe reports whether s is in the URL-safe form, as opposed to the
+// standard one which always has commas.
+func isURLSafe(s string) bool {
+	return strings.HasPrefix(s, URLPrefix) && !strings.Contains(s, ",")
+}
+
+func unmarshalURL(s string) (Token, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s[len(URLPrefix):])
+	if err != nil {
+		return Token{}, wrap("url", err)
+	}
+	return UnmarshalBinary(data)
+}
+
+// RepairError is returned by Repair when a token is damaged beyond what it
+// can restore.
+type RepairError struct {
+	// Reason the token could not be repaired.
+	Reason string
+
+	// Err is the error decoding the token failed with after repairs.
+	Err error
+}
+
+func (e *RepairError) Error() string {
+	return "sltoken repair failed: " + e.Reason + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *RepairError) Unwrap() error {
+	return e.Err
+}
+
+// Repair undoes common damage done to tokens passed in URLs and returns the
+// token, in the same form, once it decodes:
+//
+//   - a token which was URL-escaped on top of its own escaping, so that its
+//     provider reads "https%3A%2F%2F" and its escaped commas "%252C", is
+//     path-unescaped once. Tokens escaped more times than that are not
+//     recognized;
+//   - every space is turned into '+', which it was before a query string was
+//     decoded. This includes spaces in the email, which is then unlikely to
+//     match the account.
+//
+// Otherwise it fails with *RepairError. Tokens which decode as they are, and
+// those in the URL-safe form, are returned unchanged.
+func Repair(s string) (string, error) {
+	s = strings.TrimSpace(s)
+
+	_, err := UnmarshalString(s)
+	switch {
+	case err == nil:
+		return s, nil
+	case isURLSafe(s):
+		return s, &RepairError{Reason: "URL-safe token is damaged", Err: err}
+	}
+
+	if strings.Contains(s, "%3A%2F%2F") {
+		if unescaped, err := url.PathUnescape(s); err == nil {
+			s = unescaped
+		}
+	}
+	s = strings.Replace(s, " ", "+", -1)
+
+	_, err = UnmarshalString(s)
+	switch {
+	case err == nil:
+		return s, nil
+	case strings.Count(s, ",") > 3:
+		return s, &RepairError{Reason: fmt.Sprintf("token has %d fields instead of 4, its escaped commas were probably unescaped", strings.Count(s, ",")+1), Err: err}
+	case strings.Count(s, ",") < 3:
+		return s, &RepairError{Reason: "token is truncated", Err: err}
+	default:
+		return s, &RepairError{Reason: "token is corrupted", Err: err}
+	}
+}
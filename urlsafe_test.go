@@ -0,0 +1,137 @@
+package securelogin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestURLSafe(t *testing.T) {
+	for i, c := range []string{token, v2Token()} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			tok, err := UnmarshalString(c)
+			fatal(t, err)
+
+			s := MarshalURLWithSecret(tok)
+			if !strings.HasPrefix(s, URLPrefix) {
+				t.Fatalf("Expected %q prefix; got %s", URLPrefix, s)
+			}
+			if escaped := url.QueryEscape(s); escaped != s {
+				t.Fatalf("Expected no escaping in query strings; got %s", escaped)
+			}
+
+			got, err := UnmarshalString(s)
+			fatal(t, err)
+			if m := string(MarshalWithSecret(got)); m != c {
+				t.Fatalf("Expected:\t%s\nGot:\t\t\t%s", c, m)
+			}
+
+			var into Token
+			fatal(t, UnmarshalInto([]byte(s), &into))
+			if m := string(MarshalWithSecret(into)); m != c {
+				t.Fatalf("Expected:\t%s\nGot:\t\t\t%s", c, m)
+			}
+
+			var decoded Token
+			fatal(t, NewDecoder(strings.NewReader(s)).Decode(&decoded))
+			if m := string(MarshalWithSecret(decoded)); m != c {
+				t.Fatalf("Expected:\t%s\nGot:\t\t\t%s", c, m)
+			}
+		})
+	}
+}
+
+func TestURLSafeEncoder(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	enc.SetURLSafe(true)
+	fatal(t, enc.Encode(tok))
+
+	if buf.String() != MarshalURL(tok) {
+		t.Fatalf("Expected:\t%s\nGot:\t\t\t%s", MarshalURL(tok), buf)
+	}
+
+	got, err := Unmarshal(buf.Bytes())
+	fatal(t, err)
+	if got.HMACSecret.Len() != 0 {
+		t.Fatalf("Expected secret to be left out")
+	}
+	fatal(t, got.Verify(o, WithoutExpire))
+}
+
+func TestURLSafeErrors(t *testing.T) {
+	for i, c := range []string{URLPrefix + "!!", URLPrefix + "AAAA"} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if _, err := UnmarshalString(c); err == nil {
+				t.Fatalf("Expected error; got nil")
+			}
+		})
+	}
+}
+
+func TestRepair(t *testing.T) {
+	var (
+		spaced    = strings.Replace(token, "+", " ", -1)
+		unescaped = strings.Replace(token, "%2C", ",", -1)
+
+		// Escaped on top of its own escaping, after '+' became spaces.
+		escaped = strings.Replace(url.QueryEscape(spaced), "+", "%20", -1)
+	)
+
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+	urlToken := MarshalURL(tok)
+
+	if !strings.Contains(token, "+") {
+		t.Fatalf("Expected test token to have '+'")
+	}
+	for _, s := range []string{"%3A%2F%2F", "%252C", "%20"} {
+		if !strings.Contains(escaped, s) {
+			t.Fatalf("Expected %q in escaped token %s", s, escaped)
+		}
+	}
+
+	var cases = []struct {
+		in     string
+		out    string
+		reason string
+	}{
+		{token, token, ""},
+		{spaced, token, ""},
+		{" " + spaced + "\n", token, ""},
+		{url.QueryEscape(token), token, ""},
+		{escaped, token, ""},
+		{unescaped, "", "fields instead of 4"},
+		{token[:40], "", "truncated"},
+		{strings.Replace(token, "E5fa", "!!!!", 1), "", "corrupted"},
+		{urlToken, urlToken, ""},
+		{URLPrefix + "AAAA", "", "URL-safe token is damaged"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got, err := Repair(c.in)
+			if c.reason != "" {
+				var repairErr *RepairError
+				if !errors.As(err, &repairErr) {
+					t.Fatalf("Expected *RepairError; got %v", err)
+				}
+				if !strings.Contains(repairErr.Reason, c.reason) {
+					t.Fatalf("Expected reason %q; got %q", c.reason, repairErr.Reason)
+				}
+				return
+			}
+
+			fatal(t, err)
+			if got != c.out {
+				t.Fatalf("Expected:\t%s\nGot:\t\t\t%s", c.out, got)
+			}
+		})
+	}
+}
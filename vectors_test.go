@@ -0,0 +1,127 @@
+package securelogin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// vectors mirrors testdata/vectors.json, which is shared with other
+// SecureLogin implementations.
+type vectors struct {
+	Vectors []struct {
+		Name    string `json:"name"`
+		Token   string `json:"token"`
+		Decoded *struct {
+			SignedPayload string     `json:"signed_payload"`
+			Provider      string     `json:"provider"`
+			Client        string     `json:"client"`
+			Scope         url.Values `json:"scope"`
+			ExpireAt      int64      `json:"expire_at"`
+			PublicKey     string     `json:"public_key"`
+			HMACSecret    string     `json:"hmac_secret"`
+			Email         string     `json:"email"`
+		} `json:"decoded"`
+		DecodeError string `json:"decode_error"`
+		Verify      []struct {
+			Config struct {
+				Origins      []string   `json:"origins"`
+				HMAC         bool       `json:"hmac"`
+				Connect      bool       `json:"connect"`
+				Change       bool       `json:"change"`
+				IgnoreExpire bool       `json:"ignore_expire"`
+				Scope        url.Values `json:"scope"`
+			} `json:"config"`
+			Error string `json:"error"`
+		} `json:"verify"`
+	} `json:"vectors"`
+}
+
+func TestVectors(t *testing.T) {
+	f, err := os.Open("testdata/vectors.json")
+	fatal(t, err)
+	defer f.Close()
+
+	var vs vectors
+	fatal(t, json.NewDecoder(f).Decode(&vs))
+
+	for _, v := range vs.Vectors {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			tok, err := Unmarshal([]byte(v.Token))
+			if v.DecodeError != "" {
+				if err == nil {
+					t.Fatalf("Expected error in %s; got nil", v.DecodeError)
+				}
+				if !strings.Contains(err.Error(), " in "+v.DecodeError+" ") {
+					t.Fatalf("Expected error in %s; got %s", v.DecodeError, err)
+				}
+				return
+			}
+			fatal(t, err)
+
+			d := v.Decoded
+			if got := string(tok.rawPayload); got != d.SignedPayload {
+				fail(t, "signed payload", d.SignedPayload, got)
+			}
+			if got := string(MarshalPayload(tok.Payload())); got != d.SignedPayload {
+				fail(t, "marshaled payload", d.SignedPayload, got)
+			}
+			if tok.Provider != d.Provider {
+				fail(t, "provider", d.Provider, tok.Provider)
+			}
+			if tok.Client != d.Client {
+				fail(t, "client", d.Client, tok.Client)
+			}
+			if !reflect.DeepEqual(tok.Scope, d.Scope) {
+				t.Errorf("Expected scope to be %v; got %v", d.Scope, tok.Scope)
+			}
+			if tok.ExpireAt.Unix() != d.ExpireAt {
+				t.Errorf("Expected expire at to be %d; got %d", d.ExpireAt, tok.ExpireAt.Unix())
+			}
+			if got := base64Encode(tok.PublicKey); got != d.PublicKey {
+				fail(t, "public key", d.PublicKey, got)
+			}
+			if got := base64Encode(tok.HMACSecret.Bytes()); got != d.HMACSecret {
+				fail(t, "HMAC secret", d.HMACSecret, got)
+			}
+			if tok.Email != d.Email {
+				fail(t, "email", d.Email, tok.Email)
+			}
+
+			if got := MarshalWithSecret(tok); !bytes.Equal(got, []byte(v.Token)) {
+				t.Errorf("Expected marshal to be\n%s\ngot\n%s", v.Token, got)
+			}
+
+			for _, c := range v.Verify {
+				opts := []Option{WithOrigins(c.Config.Origins...), WithScope(c.Config.Scope)}
+				if c.Config.HMAC {
+					opts = append(opts, WithHMAC)
+				}
+				if c.Config.Connect {
+					opts = append(opts, WithConnect)
+				}
+				if c.Config.Change {
+					opts = append(opts, WithChange)
+				}
+				if c.Config.IgnoreExpire {
+					opts = append(opts, WithoutExpire)
+				}
+
+				err := tok.Verify(opts...)
+				switch {
+				case c.Error == "" && err != nil:
+					t.Errorf("%+v: unexpected error: %s", c.Config, err)
+				case c.Error != "" && err == nil:
+					t.Errorf("%+v: expected error %q; got nil", c.Config, c.Error)
+				case c.Error != "" && c.Error != err.Error():
+					t.Errorf("%+v: expected error %q; got %q", c.Config, c.Error, err)
+				}
+			}
+		})
+	}
+}
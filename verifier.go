@@ -0,0 +1,161 @@
+package securelogin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Verifier verifies tokens with a fixed configuration. It's safe for
+// concurrent use and avoids building the configuration on every call.
+type Verifier struct {
+	cfg Config
+}
+
+// NewVerifier returns a Verifier with given options.
+func NewVerifier(opts ...Option) *Verifier {
+	return &Verifier{cfg: NewConfig(opts...)}
+}
+
+// Verify decodes and verifies token.
+func (v *Verifier) Verify(ctx context.Context, token []byte) (Result, error) {
+	t, err := Unmarshal(token)
+	if err != nil {
+		return Result{Token: t}, err
+	}
+
+	return t.verify(ctx, v.cfg)
+}
+
+// VerifyToken verifies already decoded t.
+func (v *Verifier) VerifyToken(ctx context.Context, t Token) (Result, error) {
+	return t.verify(ctx, v.cfg)
+}
+
+// ReloadEvent reports the outcome of a Reloader.Reload.
+type ReloadEvent struct {
+	Time time.Time
+
+	// Err is nil if the reload succeeded. Otherwise the previous
+	// configuration is still in use.
+	Err error
+}
+
+// Reloader verifies tokens with a configuration which can be swapped at
+// runtime. Verification never waits for a reload and verifications in
+// flight finish with the configuration they started with.
+type Reloader struct {
+	load     func() ([]Option, error)
+	onReload func(ReloadEvent)
+
+	mu       sync.Mutex // serializes reloads
+	verifier atomic.Value
+}
+
+// NewReloader returns a Reloader which obtains its options from load. The
+// initial load has to succeed. If onReload is not nil, it's called after
+// every following reload.
+func NewReloader(load func() ([]Option, error), onReload func(ReloadEvent)) (*Reloader, error) {
+	r := &Reloader{load: load}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	r.onReload = onReload
+	return r, nil
+}
+
+// SettingsLoader returns a load function for NewReloader, which reads
+// Settings with LoadSettings.
+func SettingsLoader(path string) func() ([]Option, error) {
+	return func() ([]Option, error) {
+		s, err := LoadSettings(path)
+		if err != nil {
+			return nil, err
+		}
+		return s.Options()
+	}
+}
+
+// Reload loads the options and swaps the configuration. On failure the
+// previous configuration is kept.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	opts, err := r.load()
+	if err == nil {
+		r.verifier.Store(NewVerifier(opts...))
+	}
+
+	if r.onReload != nil {
+		r.onReload(ReloadEvent{Time: time.Now(), Err: err})
+	}
+	return err
+}
+
+// Verifier returns the Verifier with the current configuration.
+func (r *Reloader) Verifier() *Verifier {
+	return r.verifier.Load().(*Verifier)
+}
+
+// Verify decodes and verifies token with the current configuration.
+func (r *Reloader) Verify(ctx context.Context, token []byte) (Result, error) {
+	return r.Verifier().Verify(ctx, token)
+}
+
+// WatchFile reloads whenever the modification time or the size of the file
+// at path changes, checking every interval. It blocks until ctx is done.
+func (r *Reloader) WatchFile(ctx context.Context, path string, interval time.Duration) error {
+	last, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		fi, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			// Probably being replaced, try again later.
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if fi.ModTime().Equal(last.ModTime()) && fi.Size() == last.Size() {
+			continue
+		}
+
+		last = fi
+		r.Reload()
+	}
+}
+
+// WatchSignal reloads whenever one of sigs is received, typically
+// syscall.SIGHUP. It blocks until ctx is done.
+func (r *Reloader) WatchSignal(ctx context.Context, sigs ...os.Signal) error {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ch:
+			r.Reload()
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package securelogin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestVerifier(t *testing.T) {
+	v := NewVerifier(o, WithoutExpire)
+
+	res, err := v.Verify(context.Background(), []byte(token))
+	fatal(t, err)
+	if res.Token.Email != "homakov@gmail.com" {
+		t.Fatalf("Expected token of homakov@gmail.com; got %q", res.Token.Email)
+	}
+
+	if _, err := v.Verify(context.Background(), []byte("garbage")); err == nil {
+		t.Fatalf("Expected error for garbage")
+	}
+
+	_, err = NewVerifier(o).VerifyToken(context.Background(), res.Token)
+	if err != ErrExpired {
+		t.Fatalf("Expected %v; got %v", ErrExpired, err)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	var (
+		origins = []string{"https://example.com"}
+		loadErr error
+		events  []ReloadEvent
+	)
+
+	load := func() ([]Option, error) {
+		return []Option{WithOrigins(origins...), WithoutExpire}, loadErr
+	}
+
+	r, err := NewReloader(load, func(e ReloadEvent) { events = append(events, e) })
+	fatal(t, err)
+
+	if _, err := r.Verify(context.Background(), []byte(token)); err != ErrInvalidProvider {
+		t.Fatalf("Expected %v; got %v", ErrInvalidProvider, err)
+	}
+
+	origins = []string{domain}
+	fatal(t, r.Reload())
+	_, err = r.Verify(context.Background(), []byte(token))
+	fatal(t, err)
+
+	origins, loadErr = nil, errors.New("broken config")
+	if err := r.Reload(); err != loadErr {
+		t.Fatalf("Expected %v; got %v", loadErr, err)
+	}
+	_, err = r.Verify(context.Background(), []byte(token))
+	fatal(t, err)
+
+	if len(events) != 2 || events[0].Err != nil || events[1].Err != loadErr {
+		t.Fatalf("Expected successful and failed reload events; got %#v", events)
+	}
+}
+
+func TestNewReloaderFails(t *testing.T) {
+	loadErr := errors.New("broken config")
+	_, err := NewReloader(func() ([]Option, error) { return nil, loadErr }, nil)
+	if err != loadErr {
+		t.Fatalf("Expected %v; got %v", loadErr, err)
+	}
+}
+
+func TestReloaderConcurrent(t *testing.T) {
+	r, err := NewReloader(func() ([]Option, error) { return []Option{o, WithoutExpire}, nil }, nil)
+	fatal(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := r.Verify(context.Background(), []byte(token)); err != nil {
+					t.Errorf("Unexpected error: %s", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				r.Reload()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestReloaderWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "securelogin.json")
+	fatal(t, os.WriteFile(path, []byte(`{"origins": ["https://example.com"], "ignore_expire": true}`), 0600))
+
+	events := make(chan ReloadEvent, 10)
+	r, err := NewReloader(SettingsLoader(path), func(e ReloadEvent) { events <- e })
+	fatal(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- r.WatchFile(ctx, path, time.Millisecond) }()
+
+	// WatchFile may not have seen the initial file yet, so keep writing.
+	for reloaded := false; !reloaded; {
+		fatal(t, os.WriteFile(path, []byte(`{"origins": ["https://cobased.com"], "ignore_expire": true}`), 0600))
+		select {
+		case e := <-events:
+			fatal(t, e.Err)
+			reloaded = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	_, err = r.Verify(context.Background(), []byte(token))
+	fatal(t, err)
+
+	fatal(t, os.WriteFile(path, []byte(`{"origins": "broken"}`), 0600))
+	expectReload(t, events, true)
+	_, err = r.Verify(context.Background(), []byte(token))
+	fatal(t, err)
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Expected %v; got %v", context.Canceled, err)
+	}
+}
+
+func TestReloaderWatchSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are not supported on windows")
+	}
+
+	events := make(chan ReloadEvent, 10)
+	r, err := NewReloader(func() ([]Option, error) { return []Option{o}, nil }, func(e ReloadEvent) { events <- e })
+	fatal(t, err)
+
+	// Catch SIGHUP before WatchSignal does, so it doesn't kill the test.
+	caught := make(chan os.Signal, 1)
+	signal.Notify(caught, syscall.SIGHUP)
+	defer signal.Stop(caught)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.WatchSignal(ctx, syscall.SIGHUP)
+
+	p, err := os.FindProcess(os.Getpid())
+	fatal(t, err)
+
+	// WatchSignal may not have subscribed yet, so keep signaling.
+	for {
+		fatal(t, p.Signal(syscall.SIGHUP))
+		select {
+		case e := <-events:
+			fatal(t, e.Err)
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func expectReload(t *testing.T, events chan ReloadEvent, failed bool) {
+	t.Helper()
+
+	select {
+	case e := <-events:
+		if (e.Err != nil) != failed {
+			t.Fatalf("Expected failed reload to be %t; got %v", failed, e.Err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected reload")
+	}
+}
@@ -1,9 +1,9 @@
 package securelogin
 
 import (
+	"context"
 	"crypto/hmac"
-	"crypto/sha512"
-	"errors"
+	"fmt"
 	"net/url"
 
 	"golang.org/x/crypto/ed25519"
@@ -14,38 +14,69 @@ import (
 // This is just a convenient function which unmarshals a token and then calls
 // Verify on it with given options.
 func Verify(token []byte, opts ...Option) (Token, error) {
+	return VerifyContext(context.Background(), token, opts...)
+}
+
+// VerifyContext verifies encoded token like Verify does, see
+// Token.VerifyContext.
+func VerifyContext(ctx context.Context, token []byte, opts ...Option) (Token, error) {
 	t, err := Unmarshal(token)
 	if err != nil {
 		return t, err
 	}
 
-	return t, t.Verify(opts...)
+	return t, t.VerifyContext(ctx, opts...)
+}
+
+// checkpoint returns ctx.Err() wrapped in *VerifyError if ctx is done.
+func checkpoint(ctx context.Context, stage string) error {
+	if err := ctx.Err(); err != nil {
+		return &VerifyError{Stage: stage, Err: err}
+	}
+	return nil
 }
 
-func verifyHMAC(message, signature, secret []byte) bool {
-	mac := hmac.New(sha512.New, secret)
-	mac.Write(message)
-	return hmac.Equal(signature, mac.Sum(nil)[:32])
+func verifyMAC(alg MACAlgorithm, message, signature, secret []byte) bool {
+	return hmac.Equal(signature, alg.Sum(secret, message))
 }
 
+const publicKeySize = ed25519.PublicKeySize
+
 func verifySignature(message, signature, pubkey []byte) bool {
-	if len(pubkey) != ed25519.PublicKeySize {
+	if len(pubkey) != publicKeySize {
 		return false
 	}
 	return ed25519.Verify(pubkey, message, signature)
 }
 
-func verifyScope(cfg Config, scope url.Values) error {
-	if cfg.change {
-		_, hasTo := scope["to"]
-		if !(len(scope) == 2 && hasTo && has(scope, "mode", "change")) {
-			return errors.New("not mode=change token")
+// verifyScope checks scope and returns the mode it was verified by.
+func verifyScope(cfg Config, scope url.Values) (string, error) {
+	if cfg.nonceStore != nil {
+		scope = withoutNonce(scope)
+		cfg.scope = withoutNonce(cfg.scope)
+	}
+
+	mode := cfg.mode
+	if mode != "" {
+		if !has(scope, ModeKey, mode) {
+			return "", fmt.Errorf("not mode=%s token", mode)
 		}
-	} else if !scopesMatch(scope, cfg.scope) {
-		return errors.New("invalid scope")
+	} else {
+		mode = scopeMode(cfg, scope)
 	}
 
-	return nil
+	if mode == "" {
+		if !scopesMatch(scope, cfg.scope) {
+			return "", ErrInvalidScope
+		}
+		return "", nil
+	}
+
+	m, err := lookupMode(mode)
+	if err != nil {
+		return "", err
+	}
+	return mode, m.Validate(scope)
 }
 
 func has(store url.Values, key, value string) bool {
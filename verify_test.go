@@ -1,7 +1,12 @@
 package securelogin
 
-import "testing"
-import "time"
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
 
 const (
 	domain = "https://cobased.com"
@@ -47,3 +52,53 @@ func TestEmptyTokenDoesntVerify(t *testing.T) {
 func fail(t *testing.T, name, expected, got interface{}) {
 	t.Errorf("Expected %s to be %q; got %q", name, expected, got)
 }
+
+// blockingNonceStore blocks until the context is done.
+type blockingNonceStore struct{}
+
+func (blockingNonceStore) Put(ctx context.Context, nonce, session string, expireAt time.Time) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (blockingNonceStore) Take(ctx context.Context, nonce string) (string, time.Time, error) {
+	<-ctx.Done()
+	return "", time.Time{}, ctx.Err()
+}
+
+func TestVerifyContext(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := VerifyContext(canceled, []byte(token), WithOrigins(domain), WithoutExpire)
+	expectVerifyError(t, err, "signature", context.Canceled)
+
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+	tok = tokScopeChange(url.Values{NonceKey: []string{"nonce"}})(tok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = tok.VerifyContext(ctx, WithOrigins(domain), WithNonce(blockingNonceStore{}, "session"))
+	expectVerifyError(t, err, "nonce", context.DeadlineExceeded)
+
+	if _, err := VerifyContext(context.Background(), []byte(token), WithOrigins(domain), WithoutExpire); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+}
+
+func expectVerifyError(t *testing.T, err error, stage string, target error) {
+	t.Helper()
+
+	var verr *VerifyError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected *VerifyError; got %#v", err)
+	}
+	if verr.Stage != stage {
+		t.Fatalf("Expected stage %q; got %q", stage, verr.Stage)
+	}
+	if !errors.Is(err, target) {
+		t.Fatalf("Expected %v; got %v", target, err)
+	}
+}
@@ -0,0 +1,147 @@
+package securelogin
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Version1 is the original token format. It has no version marker on the
+// wire, signs with Ed25519 and authenticates with HMAC-SHA512 truncated to
+// 256 bits.
+const Version1 = 1
+
+// SignatureAlgorithm verifies public key signatures of token payloads.
+type SignatureAlgorithm interface {
+	Verify(pubkey, message, signature []byte) bool
+}
+
+// MACAlgorithm computes message authentication codes of token payloads.
+type MACAlgorithm interface {
+	Sum(secret, message []byte) []byte
+}
+
+// Algorithms used by a token format version.
+type Algorithms struct {
+	Signature SignatureAlgorithm
+	MAC       MACAlgorithm
+}
+
+// Ed25519 is the SignatureAlgorithm of Version1.
+var Ed25519 SignatureAlgorithm = ed25519Algorithm{}
+
+// HMACSHA512_256 is the MACAlgorithm of Version1. It is HMAC-SHA512 with
+// the output truncated to 256 bits, not SHA-512/256.
+var HMACSHA512_256 MACAlgorithm = hmacSHA512_256{}
+
+type ed25519Algorithm struct{}
+
+func (ed25519Algorithm) Verify(pubkey, message, signature []byte) bool {
+	return verifySignature(message, signature, pubkey)
+}
+
+type hmacSHA512_256 struct{}
+
+func (hmacSHA512_256) Sum(secret, message []byte) []byte {
+	mac := hmac.New(sha512.New, secret)
+	mac.Write(message)
+	return mac.Sum(nil)[:32]
+}
+
+var versions = struct {
+	sync.RWMutex
+	m map[int]Algorithms
+}{m: map[int]Algorithms{Version1: {Ed25519, HMACSHA512_256}}}
+
+// RegisterVersion makes tokens of given format version decodable and
+// verifiable with algs. Versions after Version1 are marked on the wire by a
+// leading "v<version>," field, which is signed along with the payload. It
+// panics if called twice for the same version, for versions before 2 or if
+// any of the algorithms is nil.
+func RegisterVersion(version int, algs Algorithms) {
+	versions.Lock()
+	defer versions.Unlock()
+
+	if version <= Version1 {
+		panic("securelogin: RegisterVersion called for version " + strconv.Itoa(version))
+	}
+	if algs.Signature == nil || algs.MAC == nil {
+		panic("securelogin: RegisterVersion algorithm is nil")
+	}
+	if _, dup := versions.m[version]; dup {
+		panic("securelogin: RegisterVersion called twice for version " + strconv.Itoa(version))
+	}
+	versions.m[version] = algs
+}
+
+func lookupVersion(version int) (Algorithms, error) {
+	if version == 0 {
+		version = Version1
+	}
+
+	versions.RLock()
+	defer versions.RUnlock()
+
+	algs, ok := versions.m[version]
+	if !ok {
+		return algs, fmt.Errorf("unsupported version %d", version)
+	}
+	return algs, nil
+}
+
+// versionPrefix returns the wire marker of version, which is empty for
+// Version1.
+func versionPrefix(version int) string {
+	if version <= Version1 {
+		return ""
+	}
+	return "v" + strconv.Itoa(version) + ","
+}
+
+// parseVersion splits the version marker off s. Tokens without one are
+// Version1.
+func parseVersion(s []byte) (int, []byte, error) {
+	if len(s) < 2 || s[0] != 'v' || s[1] < '0' || s[1] > '9' {
+		return Version1, s, nil
+	}
+
+	i := 1
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	if i == len(s) || s[i] != ',' {
+		return Version1, s, nil
+	}
+
+	version, ok := parseInt64(s[1:i])
+	if !ok || version <= Version1 || version > 1<<16 {
+		return 0, s, fmt.Errorf("invalid version %q", s[:i])
+	}
+
+	if _, err := lookupVersion(int(version)); err != nil {
+		return 0, s, err
+	}
+	return int(version), s[i+1:], nil
+}
+
+// SignedMessage returns what the signature and the MAC of t are made over:
+// its payload as sent, see MarshalPayload, prefixed by the version marker
+// for versions after Version1. The result is a copy, which the caller may
+// modify.
+func (t Token) SignedMessage() []byte {
+	if t.Version <= Version1 {
+		return append([]byte(nil), t.rawPayload...)
+	}
+	return t.signedMessage()
+}
+
+// signedMessage is SignedMessage without the copy for Version1 tokens. The
+// result must not be modified.
+func (t Token) signedMessage() []byte {
+	if t.Version <= Version1 {
+		return t.rawPayload
+	}
+	return append([]byte(versionPrefix(t.Version)), t.rawPayload...)
+}
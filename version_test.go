@@ -0,0 +1,149 @@
+package securelogin
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+type hmacSHA256 struct{}
+
+func (hmacSHA256) Sum(secret, message []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+func init() {
+	RegisterVersion(2, Algorithms{Signature: Ed25519, MAC: hmacSHA256{}})
+}
+
+// v2Token returns a token in format version 2 signed by a fresh key.
+func v2Token() string {
+	var (
+		private = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+		secret  = []byte("0123456789abcdef0123456789abcdef")
+		payload = "https://cobased.com,https://cobased.com,,4102444800"
+		message = []byte("v2," + payload)
+	)
+
+	return "v2," + escapeJoin([]string{
+		payload,
+		escapeJoin([]string{
+			base64Encode(ed25519.Sign(private, message)),
+			base64Encode(hmacSHA256{}.Sum(secret, message)),
+		}),
+		escapeJoin([]string{
+			base64Encode(private.Public().(ed25519.PublicKey)),
+			base64Encode(secret),
+		}),
+		"homakov@gmail.com",
+	})
+}
+
+func TestVersion2(t *testing.T) {
+	v2 := v2Token()
+
+	tok, err := UnmarshalString(v2)
+	fatal(t, err)
+	if tok.Version != 2 {
+		t.Fatalf("Expected version 2; got %d", tok.Version)
+	}
+	fatal(t, tok.Verify(o, WithHMAC))
+
+	if got := string(MarshalWithSecret(tok)); got != v2 {
+		t.Fatalf("Expected:\t%s\nGot:\t\t\t%s", v2, got)
+	}
+
+	var into Token
+	fatal(t, UnmarshalInto([]byte(v2), &into))
+	if !reflect.DeepEqual(tok, into) {
+		t.Fatalf("Expected %#v; got %#v", tok, into)
+	}
+
+	var decoded Token
+	fatal(t, NewDecoder(strings.NewReader(v2)).Decode(&decoded))
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	enc.SetIncludeSecret(true)
+	fatal(t, enc.Encode(decoded))
+	if buf.String() != v2 {
+		t.Fatalf("Expected:\t%s\nGot:\t\t\t%s", v2, buf)
+	}
+}
+
+func TestVersion1IsImplicit(t *testing.T) {
+	tok, err := UnmarshalString(token)
+	fatal(t, err)
+	if tok.Version != Version1 {
+		t.Fatalf("Expected version %d; got %d", Version1, tok.Version)
+	}
+
+	tok.Version = 0
+	if string(MarshalWithSecret(tok)) != token {
+		t.Fatalf("Expected zero version to marshal as version 1")
+	}
+	fatal(t, tok.Verify(o, WithoutExpire, WithHMAC))
+}
+
+func TestVersionDowngrade(t *testing.T) {
+	tok, err := UnmarshalString(strings.TrimPrefix(v2Token(), "v2,"))
+	fatal(t, err)
+
+	if err := tok.Verify(o); err == nil || err.Error() != "invalid signature" {
+		t.Fatalf("Expected invalid signature; got %v", err)
+	}
+
+	tok.Version = 3
+	if err := tok.Verify(o); err == nil || err.Error() != "unsupported version 3" {
+		t.Fatalf("Expected unsupported version; got %v", err)
+	}
+}
+
+func TestVersionErrors(t *testing.T) {
+	rest := strings.TrimPrefix(v2Token(), "v2,")
+
+	var cases = []struct {
+		str string
+		err error
+	}{
+		{"v3," + rest, wrap("version", "unsupported version 3")},
+		{"v1," + rest, wrap("version", `invalid version "v1"`)},
+		{"v99999999999999999999," + rest, wrap("version", `invalid version "v99999999999999999999"`)},
+		{"v2", wrap("token", "expected 4 elements, got 1")},
+	}
+
+	for _, c := range cases {
+		_, err := UnmarshalString(c.str)
+		compareErrors(t, c.err, err)
+
+		err = UnmarshalInto([]byte(c.str), new(Token))
+		compareErrors(t, c.err, err)
+	}
+}
+
+func TestRegisterVersionPanics(t *testing.T) {
+	for _, c := range []struct {
+		version int
+		algs    Algorithms
+	}{
+		{1, Algorithms{Ed25519, HMACSHA512_256}},
+		{2, Algorithms{Ed25519, HMACSHA512_256}},
+		{4, Algorithms{Ed25519, nil}},
+		{4, Algorithms{nil, HMACSHA512_256}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected RegisterVersion(%d) to panic", c.version)
+				}
+			}()
+			RegisterVersion(c.version, c.algs)
+		}()
+	}
+}